@@ -0,0 +1,572 @@
+package symbolic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AppleCrashReport is an iOS / macOS crash report, parsed from either the .ips JSON
+// format or the legacy .crash text format
+type AppleCrashReport struct {
+	// Header holds the lines before the first thread of a legacy report, they are rendered as is
+	Header        []string
+	Process       string
+	PID           int
+	CodeType      string
+	ExceptionType string
+	Threads       []*AppleCrashThread
+	// LastExceptionBacktrace is the backtrace of the uncaught NSException, if any
+	LastExceptionBacktrace []*AppleCrashFrame
+	BinaryImages           []*AppleBinaryImage
+}
+
+// AppleCrashThread is the backtrace of one thread, the first frame is the innermost
+type AppleCrashThread struct {
+	Index   int
+	Name    string
+	Crashed bool
+	Frames  []*AppleCrashFrame
+}
+
+// AppleCrashFrame is one frame of a backtrace
+type AppleCrashFrame struct {
+	Index     int
+	ImageName string
+	// Image is the binary image containing Address, nil when the report does not list it
+	Image   *AppleBinaryImage
+	Address uint64
+	// Symbol is the symbol named by the report itself, e.g. for system libraries
+	Symbol string
+	// Locations is set by Symbolicate, inlined functions come first
+	Locations []SourceLocation
+	// Err is set when looking up the frame failed
+	Err error
+
+	// load is the load address a legacy frame names, used when the image is not listed
+	load uint64
+}
+
+// RelativeAddress is the address relative to the load address of its image, as looked up in a SymCache
+func (f *AppleCrashFrame) RelativeAddress() uint64 {
+	if f.Image == nil || f.Address < f.Image.LoadAddress {
+		return 0
+	}
+
+	return f.Address - f.Image.LoadAddress
+}
+
+// lookupAddress is the relative address to look the frame up at. A return address points
+// behind its call, so it is moved back into the calling instruction.
+func (f *AppleCrashFrame) lookupAddress(isReturnAddress bool) uint64 {
+	addr := f.RelativeAddress()
+	if isReturnAddress && addr > 0 {
+		addr--
+	}
+
+	return addr
+}
+
+// AppleBinaryImage is an executable or library loaded into the crashed process
+type AppleBinaryImage struct {
+	Name string
+	Arch string
+	// DebugID is the image UUID in the format of SymCache debug IDs
+	DebugID     string
+	Path        string
+	LoadAddress uint64
+	EndAddress  uint64
+}
+
+// ParseAppleCrashReport reads a crash report, both the .ips JSON format of iOS 15 / macOS 12
+// and later and the legacy .crash text format are accepted
+func ParseAppleCrashReport(r io.Reader) (*AppleCrashReport, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty crash report")
+	}
+
+	if data[0] == '{' {
+		return parseIPSCrashReport(data)
+	}
+
+	return parseTextCrashReport(data)
+}
+
+// Symbolicate looks up every frame in the SymCache of its image's debug ID from the archives.
+// Frames of images no archive has a SymCache for are left as they are.
+func (r *AppleCrashReport) Symbolicate(archives ...*Archive) {
+	symCaches := make(map[string]*SymCache)
+	errs := make(map[string]error)
+
+	symCacheFor := func(debugID string) (*SymCache, error) {
+		if symCache, ok := symCaches[debugID]; ok {
+			return symCache, errs[debugID]
+		}
+
+		for _, a := range archives {
+			if a == nil {
+				continue
+			}
+
+			symCache, err := a.SymCache(debugID)
+			if err != nil || symCache != nil {
+				symCaches[debugID], errs[debugID] = symCache, err
+				return symCache, err
+			}
+		}
+
+		symCaches[debugID] = nil
+		return nil, nil
+	}
+
+	// only the innermost frame of a thread holds the crashing instruction itself, every
+	// frame of the exception backtrace is a return address, including the first
+	symbolicate := func(frames []*AppleCrashFrame, returnAddresses bool) {
+		for i, frame := range frames {
+			if frame.Image == nil || frame.Image.DebugID == "" {
+				continue
+			}
+
+			symCache, err := symCacheFor(frame.Image.DebugID)
+			if err != nil {
+				frame.Err = err
+				continue
+			}
+			if symCache == nil {
+				continue
+			}
+
+			frame.Locations, frame.Err = symCache.Lookup(frame.lookupAddress(returnAddresses || i > 0))
+		}
+	}
+
+	for _, thread := range r.Threads {
+		symbolicate(thread.Frames, false)
+	}
+	symbolicate(r.LastExceptionBacktrace, true)
+}
+
+// imageFor finds the image containing addr, falling back to the image name
+func (r *AppleCrashReport) imageFor(addr uint64, name string) *AppleBinaryImage {
+	for _, image := range r.BinaryImages {
+		if addr >= image.LoadAddress && addr <= image.EndAddress {
+			return image
+		}
+	}
+
+	for _, image := range r.BinaryImages {
+		if name != "" && image.Name == name {
+			return image
+		}
+	}
+
+	return nil
+}
+
+// resolveImages finds the image of every frame. Images a legacy report does not list are
+// made up from the load address the frames name, shared by the frames of the image and
+// added to BinaryImages once.
+func (r *AppleCrashReport) resolveImages() {
+	type imageKey struct {
+		name string
+		load uint64
+	}
+	synthetic := make(map[imageKey]*AppleBinaryImage)
+	var added []*AppleBinaryImage
+
+	resolve := func(frames []*AppleCrashFrame) {
+		for _, frame := range frames {
+			if frame.Image != nil {
+				continue
+			}
+
+			frame.Image = r.imageFor(frame.Address, frame.ImageName)
+			if frame.Image != nil || frame.load == 0 {
+				continue
+			}
+
+			key := imageKey{name: frame.ImageName, load: frame.load}
+			image, ok := synthetic[key]
+			if !ok {
+				image = &AppleBinaryImage{Name: frame.ImageName, LoadAddress: frame.load, EndAddress: frame.Address}
+				synthetic[key] = image
+				added = append(added, image)
+			}
+			if frame.Address > image.EndAddress {
+				image.EndAddress = frame.Address
+			}
+			frame.Image = image
+		}
+	}
+
+	for _, thread := range r.Threads {
+		resolve(thread.Frames)
+	}
+	resolve(r.LastExceptionBacktrace)
+
+	r.BinaryImages = append(r.BinaryImages, added...)
+}
+
+// ips reports are a JSON header line followed by the JSON body
+type ipsCrashReport struct {
+	ProcName  string `json:"procName"`
+	PID       int    `json:"pid"`
+	CPUType   string `json:"cpuType"`
+	Exception struct {
+		Type   string `json:"type"`
+		Signal string `json:"signal"`
+	} `json:"exception"`
+	FaultingThread         *int        `json:"faultingThread"`
+	Threads                []ipsThread `json:"threads"`
+	LastExceptionBacktrace []ipsFrame  `json:"lastExceptionBacktrace"`
+	UsedImages             []struct {
+		Source string `json:"source"`
+		Arch   string `json:"arch"`
+		Base   uint64 `json:"base"`
+		Size   uint64 `json:"size"`
+		UUID   string `json:"uuid"`
+		Path   string `json:"path"`
+		Name   string `json:"name"`
+	} `json:"usedImages"`
+}
+
+type ipsThread struct {
+	Triggered bool       `json:"triggered"`
+	Name      string     `json:"name"`
+	Queue     string     `json:"queue"`
+	Frames    []ipsFrame `json:"frames"`
+}
+
+type ipsFrame struct {
+	ImageOffset    uint64 `json:"imageOffset"`
+	ImageIndex     *int   `json:"imageIndex"`
+	Symbol         string `json:"symbol"`
+	SymbolLocation uint64 `json:"symbolLocation"`
+}
+
+func parseIPSCrashReport(data []byte) (*AppleCrashReport, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// the header is followed by the body, older reports only have the body
+	var header, body json.RawMessage
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("reading ips crash report: %w", err)
+	}
+	if err := dec.Decode(&body); errors.Is(err, io.EOF) {
+		body = header
+	} else if err != nil {
+		return nil, fmt.Errorf("reading ips crash report: %w", err)
+	}
+
+	var ips ipsCrashReport
+	if err := json.Unmarshal(body, &ips); err != nil {
+		return nil, fmt.Errorf("reading ips crash report: %w", err)
+	}
+
+	r := &AppleCrashReport{
+		Process:       ips.ProcName,
+		PID:           ips.PID,
+		CodeType:      ips.CPUType,
+		ExceptionType: ips.Exception.Type,
+	}
+	if ips.Exception.Signal != "" {
+		r.ExceptionType += " (" + ips.Exception.Signal + ")"
+	}
+
+	for _, img := range ips.UsedImages {
+		image := &AppleBinaryImage{
+			Name:        img.Name,
+			Arch:        img.Arch,
+			DebugID:     normalizeDebugID(img.UUID),
+			Path:        img.Path,
+			LoadAddress: img.Base,
+			EndAddress:  img.Base,
+		}
+		if img.Size > 0 {
+			image.EndAddress = img.Base + img.Size - 1
+		}
+		r.BinaryImages = append(r.BinaryImages, image)
+	}
+
+	frames := func(ipsFrames []ipsFrame) []*AppleCrashFrame {
+		result := make([]*AppleCrashFrame, len(ipsFrames))
+		for i, f := range ipsFrames {
+			frame := &AppleCrashFrame{Index: i}
+			if f.Symbol != "" {
+				frame.Symbol = fmt.Sprintf("%s + %d", f.Symbol, f.SymbolLocation)
+			}
+			// frames without an image index are not in any image, index 0 is a real image
+			if f.ImageIndex != nil && *f.ImageIndex >= 0 && *f.ImageIndex < len(r.BinaryImages) {
+				frame.Image = r.BinaryImages[*f.ImageIndex]
+				frame.ImageName = frame.Image.Name
+				frame.Address = frame.Image.LoadAddress + f.ImageOffset
+			}
+			result[i] = frame
+		}
+		return result
+	}
+
+	for i, t := range ips.Threads {
+		thread := &AppleCrashThread{
+			Index:   i,
+			Name:    t.Name,
+			Crashed: t.Triggered || (ips.FaultingThread != nil && *ips.FaultingThread == i),
+			Frames:  frames(t.Frames),
+		}
+		if thread.Name == "" && t.Queue != "" {
+			thread.Name = "Dispatch queue: " + t.Queue
+		}
+		r.Threads = append(r.Threads, thread)
+	}
+	r.LastExceptionBacktrace = frames(ips.LastExceptionBacktrace)
+
+	return r, nil
+}
+
+var (
+	// iOS: "Thread 0 Crashed:", macOS: "Thread 0 Crashed:: Dispatch queue: com.apple.main-thread"
+	crashThreadRegex     = regexp.MustCompile(`^Thread (\d+)( Crashed)?:(?::\s*(.*?))?\s*$`)
+	crashThreadNameRegex = regexp.MustCompile(`^Thread (\d+) name:\s*(.*)$`)
+	// 1   crashcrashcrash   0x0000000102a3b5c4 0x102a34000 + 30148
+	crashFrameRegex = regexp.MustCompile(`^(\d+)\s+(.+?)\s+(0x[0-9a-fA-F]+)\s+(.*)$`)
+	// an unsymbolicated frame names the load address and offset
+	crashFrameOffsetRegex = regexp.MustCompile(`^(0x[0-9a-fA-F]+) \+ (\d+)$`)
+	// 0x102a34000 - 0x102a3ffff crashcrashcrash arm64  <0c3a0bf2a5b637d1a1f1a8c8bda3d6c8> /path/to/crashcrashcrash
+	// 0x107bb9000 - 0x107bb9ff7 +Electron (1.8.1 - 1.8.1) <CB63147A-C9DC-308B-8CA1-EE92A5042E8E> /path/to/Electron
+	// images made up from frames have no UUID
+	crashImageRegex   = regexp.MustCompile(`^\s*(0x[0-9a-fA-F]+)\s*-\s*(0x[0-9a-fA-F]+)\s+\+?(.+?)(?:\s+\(([^)]*)\))?\s+(?:(\w+)\s+)?<([0-9a-fA-F-]*)>\s*(.*)$`)
+	crashHeaderRegex  = regexp.MustCompile(`^([A-Za-z][A-Za-z ]*):\s+(.*)$`)
+	crashProcessRegex = regexp.MustCompile(`^(.*?)\s*\[(\d+)\]$`)
+)
+
+func parseTextCrashReport(data []byte) (*AppleCrashReport, error) {
+	r := &AppleCrashReport{}
+
+	var frames *[]*AppleCrashFrame
+	names := make(map[int]string)
+	inHeader := true
+	inImages := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if m := crashThreadNameRegex.FindStringSubmatch(line); m != nil {
+			index, _ := strconv.Atoi(m[1])
+			names[index] = m[2]
+			inHeader = false
+			continue
+		}
+
+		if m := crashThreadRegex.FindStringSubmatch(line); m != nil {
+			index, _ := strconv.Atoi(m[1])
+			thread := &AppleCrashThread{Index: index, Name: names[index], Crashed: m[2] != ""}
+			if m[3] != "" {
+				thread.Name = m[3]
+			}
+			r.Threads = append(r.Threads, thread)
+			frames = &thread.Frames
+			inHeader, inImages = false, false
+			continue
+		}
+
+		if strings.HasPrefix(line, "Last Exception Backtrace:") {
+			frames = &r.LastExceptionBacktrace
+			inHeader, inImages = false, false
+			continue
+		}
+
+		if strings.HasPrefix(line, "Binary Images:") {
+			frames = nil
+			inHeader, inImages = false, true
+			continue
+		}
+
+		if inHeader {
+			r.Header = append(r.Header, line)
+			if m := crashHeaderRegex.FindStringSubmatch(line); m != nil {
+				r.setHeaderField(m[1], strings.TrimSpace(m[2]))
+			}
+			continue
+		}
+
+		if inImages {
+			if m := crashImageRegex.FindStringSubmatch(line); m != nil {
+				r.BinaryImages = append(r.BinaryImages, parseCrashImage(m))
+			}
+			continue
+		}
+
+		if frames == nil {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			frames = nil
+			continue
+		}
+
+		if m := crashFrameRegex.FindStringSubmatch(line); m != nil {
+			*frames = append(*frames, parseCrashFrame(m))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// trailing empty lines separate the header from the threads
+	for len(r.Header) > 0 && strings.TrimSpace(r.Header[len(r.Header)-1]) == "" {
+		r.Header = r.Header[:len(r.Header)-1]
+	}
+
+	if len(r.Threads) == 0 && len(r.BinaryImages) == 0 {
+		return nil, errors.New("no threads or binary images found in crash report")
+	}
+
+	r.resolveImages()
+
+	return r, nil
+}
+
+func (r *AppleCrashReport) setHeaderField(key, value string) {
+	switch key {
+	case "Process":
+		r.Process = value
+		if m := crashProcessRegex.FindStringSubmatch(value); m != nil {
+			r.Process = m[1]
+			r.PID, _ = strconv.Atoi(m[2])
+		}
+	case "Code Type":
+		r.CodeType = strings.TrimSuffix(value, " (Native)")
+	case "Exception Type":
+		r.ExceptionType = value
+	}
+}
+
+func parseCrashFrame(m []string) *AppleCrashFrame {
+	index, _ := strconv.Atoi(m[1])
+	addr, _ := strconv.ParseUint(m[3], 0, 64)
+
+	frame := &AppleCrashFrame{Index: index, ImageName: m[2], Address: addr}
+
+	if o := crashFrameOffsetRegex.FindStringSubmatch(m[4]); o != nil {
+		frame.load, _ = strconv.ParseUint(o[1], 0, 64)
+		return frame
+	}
+
+	frame.Symbol = m[4]
+	return frame
+}
+
+func parseCrashImage(m []string) *AppleBinaryImage {
+	load, _ := strconv.ParseUint(m[1], 0, 64)
+	end, _ := strconv.ParseUint(m[2], 0, 64)
+
+	return &AppleBinaryImage{
+		Name:        m[3],
+		Arch:        m[5],
+		DebugID:     normalizeDebugID(m[6]),
+		Path:        m[7],
+		LoadAddress: load,
+		EndAddress:  end,
+	}
+}
+
+// String renders the report in the legacy text format, with symbolicated frames
+func (r *AppleCrashReport) String() string {
+	var b strings.Builder
+	r.WriteText(&b)
+	return b.String()
+}
+
+// WriteText renders the report in the legacy text format. Symbolicated frames are written as
+// "symbol + offset (file:line)" of the function containing the frame, one line per frame.
+// Functions inlined into it are only in the frame's Locations.
+func (r *AppleCrashReport) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if len(r.Header) > 0 {
+		for _, line := range r.Header {
+			fmt.Fprintln(bw, line)
+		}
+	} else {
+		process := r.Process
+		if r.PID != 0 {
+			process = fmt.Sprintf("%s [%d]", r.Process, r.PID)
+		}
+		fmt.Fprintf(bw, "Process:               %s\n", process)
+		fmt.Fprintf(bw, "Code Type:             %s\n", r.CodeType)
+		fmt.Fprintf(bw, "Exception Type:        %s\n", r.ExceptionType)
+	}
+	fmt.Fprintln(bw)
+
+	if len(r.LastExceptionBacktrace) > 0 {
+		fmt.Fprintln(bw, "Last Exception Backtrace:")
+		writeCrashFrames(bw, r.LastExceptionBacktrace)
+		fmt.Fprintln(bw)
+	}
+
+	for _, thread := range r.Threads {
+		if thread.Name != "" {
+			fmt.Fprintf(bw, "Thread %d name:  %s\n", thread.Index, thread.Name)
+		}
+		if thread.Crashed {
+			fmt.Fprintf(bw, "Thread %d Crashed:\n", thread.Index)
+		} else {
+			fmt.Fprintf(bw, "Thread %d:\n", thread.Index)
+		}
+		writeCrashFrames(bw, thread.Frames)
+		fmt.Fprintln(bw)
+	}
+
+	fmt.Fprintln(bw, "Binary Images:")
+	for _, image := range r.BinaryImages {
+		name := image.Name
+		if image.Arch != "" {
+			name += " " + image.Arch
+		}
+		fmt.Fprintf(bw, "%#18x - %#18x %s <%s> %s\n", image.LoadAddress, image.EndAddress, name,
+			strings.ReplaceAll(image.DebugID, "-", ""), image.Path)
+	}
+
+	return bw.Flush()
+}
+
+func writeCrashFrames(w io.Writer, frames []*AppleCrashFrame) {
+	for _, frame := range frames {
+		prefix := fmt.Sprintf("%-4d%-30s\t0x%016x", frame.Index, frame.ImageName, frame.Address)
+
+		if len(frame.Locations) > 0 {
+			// inlined functions come first, the last location is the function the code belongs to
+			loc := frame.Locations[len(frame.Locations)-1]
+			fmt.Fprintf(w, "%s %s + %d", prefix, loc.Symbol, frame.RelativeAddress()-loc.SymAddr)
+			if loc.FullPath != "" {
+				fmt.Fprintf(w, " (%s:%d)", loc.FullPath, loc.Line)
+			}
+			fmt.Fprintln(w)
+			continue
+		}
+
+		switch {
+		case frame.Symbol != "":
+			fmt.Fprintf(w, "%s %s\n", prefix, frame.Symbol)
+		case frame.Image != nil:
+			fmt.Fprintf(w, "%s 0x%x + %d\n", prefix, frame.Image.LoadAddress, frame.RelativeAddress())
+		default:
+			fmt.Fprintln(w, prefix)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package symbolic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCrashReport = `Incident Identifier: 5E5A3D2B-6C1F-4E4B-9F47-4D4B9B8C2F11
+Process:               Electron [4711]
+Code Type:             X86-64 (Native)
+Exception Type:        EXC_CRASH (SIGABRT)
+
+Thread 0 name:  Dispatch queue: com.apple.main-thread
+Thread 0 Crashed:
+0   Electron                      	0x0000000107bb9f25 0x107bb9000 + 3877
+1   libdyld.dylib                 	0x00007fff6ad5a235 start + 1
+
+Thread 1:
+0   libsystem_kernel.dylib        	0x00007fff6ae9b28a 0x7fff6ae9a000 + 4746
+
+Thread 0 crashed with X86 Thread State (64-bit):
+  rax: 0x0000000000000000  rbx: 0x0000000000000000
+
+Binary Images:
+       0x107bb9000 -        0x107bb9ff7 +Electron (1.8.1 - 1.8.1) <CB63147A-C9DC-308B-8CA1-EE92A5042E8E> /Applications/Electron.app/Contents/MacOS/Electron
+    0x7fff6ad59000 -     0x7fff6ad5afff  libdyld.dylib (655.1.1) <90C801E7-5D05-37A8-810C-B58E8C53953A> /usr/lib/system/libdyld.dylib
+`
+
+// macOS names the queue on the thread line instead of a separate "name:" line
+const testMacOSCrashReport = `Process:               TextEdit [1234]
+Path:                  /System/Applications/TextEdit.app/Contents/MacOS/TextEdit
+Code Type:             ARM-64 (Native)
+Exception Type:        EXC_BAD_ACCESS (SIGSEGV)
+
+Thread 0 Crashed:: Dispatch queue: com.apple.main-thread
+0   libobjc.A.dylib               	0x0000000180a4c1a0 objc_msgSend + 32
+1   TextEdit                      	0x0000000102a3b5c4 0x102a34000 + 30148
+
+Thread 1:: com.apple.NSEventThread
+0   libsystem_kernel.dylib        	0x0000000180d2e8b0 mach_msg2_trap + 8
+
+Thread 2:
+0   libsystem_pthread.dylib       	0x0000000180d69d20 start_wqthread + 0
+
+Binary Images:
+       0x102a34000 -        0x102a3ffff com.apple.TextEdit (1.18) <0c3a0bf2-a5b6-37d1-a1f1-a8c8bda3d6c8> /System/Applications/TextEdit.app/Contents/MacOS/TextEdit
+`
+
+const testIPSCrashReport = `{"app_name":"Electron","bug_type":"309","os_version":"macOS 13.4"}
+{
+  "procName": "Electron",
+  "pid": 4711,
+  "cpuType": "X86-64",
+  "exception": {"type": "EXC_CRASH", "signal": "SIGABRT"},
+  "faultingThread": 0,
+  "threads": [
+    {"triggered": true, "queue": "com.apple.main-thread", "frames": [
+      {"imageOffset": 3877, "imageIndex": 0},
+      {"imageOffset": 4661, "symbol": "start", "symbolLocation": 1, "imageIndex": 1},
+      {"imageOffset": 4096}
+    ]},
+    {"frames": [{"imageOffset": 4746, "imageIndex": 1}]}
+  ],
+  "usedImages": [
+    {"source": "P", "arch": "x86_64", "base": 4424699904, "size": 4088, "uuid": "cb63147a-c9dc-308b-8ca1-ee92a5042e8e", "name": "Electron", "path": "/Applications/Electron.app/Contents/MacOS/Electron"},
+    {"source": "P", "arch": "x86_64", "base": 140734985768960, "size": 8192, "uuid": "90c801e7-5d05-37a8-810c-b58e8c53953a", "name": "libdyld.dylib", "path": "/usr/lib/system/libdyld.dylib"}
+  ]
+}`
+
+func TestParseAppleCrashReportText(t *testing.T) {
+	report, err := ParseAppleCrashReport(strings.NewReader(testCrashReport))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Electron", report.Process)
+	assert.Equal(t, 4711, report.PID)
+	assert.Equal(t, "X86-64", report.CodeType)
+	assert.Equal(t, "EXC_CRASH (SIGABRT)", report.ExceptionType)
+	assert.Len(t, report.Header, 4)
+
+	// the image of thread 1 is made up from its frame
+	assert.Len(t, report.BinaryImages, 3)
+	electron := report.BinaryImages[0]
+	assert.Equal(t, "Electron", electron.Name)
+	assert.Equal(t, "cb63147a-c9dc-308b-8ca1-ee92a5042e8e", electron.DebugID)
+	assert.Equal(t, uint64(0x107bb9000), electron.LoadAddress)
+	assert.Equal(t, "/Applications/Electron.app/Contents/MacOS/Electron", electron.Path)
+
+	assert.Len(t, report.Threads, 2)
+	crashed := report.Threads[0]
+	assert.True(t, crashed.Crashed)
+	assert.Equal(t, "Dispatch queue: com.apple.main-thread", crashed.Name)
+	assert.Len(t, crashed.Frames, 2)
+	assert.Same(t, electron, crashed.Frames[0].Image)
+	assert.Equal(t, uint64(0xf25), crashed.Frames[0].RelativeAddress())
+	assert.Equal(t, "start + 1", crashed.Frames[1].Symbol)
+
+	// images missing from the list keep the load address the frame names
+	other := report.Threads[1].Frames[0]
+	assert.False(t, report.Threads[1].Crashed)
+	assert.Equal(t, uint64(4746), other.RelativeAddress())
+	assert.Equal(t, "", other.Image.DebugID)
+	assert.Same(t, report.BinaryImages[2], other.Image)
+}
+
+func TestParseAppleCrashReportUnlistedImages(t *testing.T) {
+	report, err := ParseAppleCrashReport(strings.NewReader(`Process:               App [1]
+
+Last Exception Backtrace:
+0   CoreFoundation                	0x00000001a0001010 0x1a0000000 + 4112
+1   App                           	0x0000000100004020 0x100000000 + 16416
+
+Thread 0 Crashed:
+0   CoreFoundation                	0x00000001a0002020 0x1a0000000 + 8224
+1   App                           	0x0000000100004000 0x100000000 + 16384
+
+Binary Images:
+`))
+	assert.NoError(t, err)
+
+	// frames of the same unlisted image share one image, which covers all of them
+	assert.Len(t, report.BinaryImages, 2)
+	foundation := report.LastExceptionBacktrace[0].Image
+	assert.Same(t, foundation, report.Threads[0].Frames[0].Image)
+	assert.Equal(t, uint64(0x1a0000000), foundation.LoadAddress)
+	assert.Equal(t, uint64(0x1a0002020), foundation.EndAddress)
+	assert.Same(t, report.LastExceptionBacktrace[1].Image, report.Threads[0].Frames[1].Image)
+
+	// every frame of the exception backtrace is a return address, threads start with the crashing instruction
+	assert.Equal(t, uint64(4111), report.LastExceptionBacktrace[0].lookupAddress(true))
+	assert.Equal(t, uint64(8224), report.Threads[0].Frames[0].lookupAddress(false))
+}
+
+func TestParseAppleCrashReportMacOS(t *testing.T) {
+	report, err := ParseAppleCrashReport(strings.NewReader(testMacOSCrashReport))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "TextEdit", report.Process)
+	assert.Equal(t, "ARM-64", report.CodeType)
+	assert.Len(t, report.Header, 4)
+
+	if assert.Len(t, report.Threads, 3) {
+		crashed := report.Threads[0]
+		assert.True(t, crashed.Crashed)
+		assert.Equal(t, "Dispatch queue: com.apple.main-thread", crashed.Name)
+		assert.Len(t, crashed.Frames, 2)
+		assert.Equal(t, "objc_msgSend + 32", crashed.Frames[0].Symbol)
+		assert.Same(t, report.BinaryImages[0], crashed.Frames[1].Image)
+		assert.Equal(t, uint64(30148), crashed.Frames[1].RelativeAddress())
+
+		assert.False(t, report.Threads[1].Crashed)
+		assert.Equal(t, "com.apple.NSEventThread", report.Threads[1].Name)
+		assert.Equal(t, "", report.Threads[2].Name)
+		assert.Len(t, report.Threads[2].Frames, 1)
+	}
+
+	assert.Equal(t, "0c3a0bf2-a5b6-37d1-a1f1-a8c8bda3d6c8", report.BinaryImages[0].DebugID)
+}
+
+func TestParseAppleCrashReportIPS(t *testing.T) {
+	report, err := ParseAppleCrashReport(strings.NewReader(testIPSCrashReport))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Electron", report.Process)
+	assert.Equal(t, "EXC_CRASH (SIGABRT)", report.ExceptionType)
+	assert.Len(t, report.BinaryImages, 2)
+	assert.Equal(t, "cb63147a-c9dc-308b-8ca1-ee92a5042e8e", report.BinaryImages[0].DebugID)
+
+	assert.Len(t, report.Threads, 2)
+	crashed := report.Threads[0]
+	assert.True(t, crashed.Crashed)
+	assert.False(t, report.Threads[1].Crashed)
+	assert.Equal(t, "Dispatch queue: com.apple.main-thread", crashed.Name)
+	assert.Equal(t, uint64(0x107bb9f25), crashed.Frames[0].Address)
+	assert.Equal(t, uint64(0xf25), crashed.Frames[0].RelativeAddress())
+	assert.Equal(t, "start + 1", crashed.Frames[1].Symbol)
+
+	// a frame without an image index is not attributed to the first image
+	unknown := crashed.Frames[2]
+	assert.Nil(t, unknown.Image)
+	assert.Equal(t, uint64(0), unknown.Address)
+}
+
+func TestAppleCrashReportWriteText(t *testing.T) {
+	report, err := ParseAppleCrashReport(strings.NewReader(testCrashReport))
+	assert.NoError(t, err)
+
+	// one line per frame, with the function the inlined code was inlined into
+	report.Threads[0].Frames[0].Locations = []SourceLocation{
+		{SymAddr: 0xf20, Line: 12, Symbol: "inlined", FullPath: "atom/app/inlined.h"},
+		{SymAddr: 0xf00, Line: 186, Symbol: "main", FullPath: "atom/app/atom_main.cc"},
+	}
+
+	text := report.String()
+	assert.Contains(t, text, "Process:               Electron [4711]\n")
+	assert.Contains(t, text, "Thread 0 name:  Dispatch queue: com.apple.main-thread\nThread 0 Crashed:\n")
+	assert.Contains(t, text, "0   Electron                      \t0x0000000107bb9f25 main + 37 (atom/app/atom_main.cc:186)\n")
+	assert.Contains(t, text, "1   libdyld.dylib                 \t0x00007fff6ad5a235 start + 1\n")
+	assert.Contains(t, text, "0   libsystem_kernel.dylib        \t0x00007fff6ae9b28a 0x7fff6ae9a000 + 4746\n")
+	assert.NotContains(t, text, "inlined")
+	assert.Contains(t, text, "0x107bb9000 -        0x107bb9ff7 Electron <cb63147ac9dc308b8ca1ee92a5042e8e> /Applications/Electron.app/Contents/MacOS/Electron\n")
+
+	// the rendered report parses again
+	reparsed, err := ParseAppleCrashReport(strings.NewReader(text))
+	assert.NoError(t, err)
+	assert.Len(t, reparsed.Threads, 2)
+	assert.Len(t, reparsed.BinaryImages, 3)
+	assert.Equal(t, report.BinaryImages[0].DebugID, reparsed.BinaryImages[0].DebugID)
+}
+
+func TestSymbolicateAppleCrashReport(t *testing.T) {
+	archive, err := NewArchiveFromPath("symbolic/py/tests/res/electron/1.8.1/Electron/CB63147AC9DC308B8CA1EE92A5042E8E0/Electron.app.dSYM/Contents/Resources/DWARF/Electron", WithLazySymCaches())
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+
+	report, err := ParseAppleCrashReport(strings.NewReader(testCrashReport))
+	assert.NoError(t, err)
+
+	report.Symbolicate(archive)
+
+	frame := report.Threads[0].Frames[0]
+	assert.NoError(t, frame.Err)
+	if assert.NotEmpty(t, frame.Locations) {
+		assert.Equal(t, "main", frame.Locations[0].Symbol)
+		assert.Equal(t, uint32(186), frame.Locations[0].Line)
+	}
+
+	// no archive has the system libraries
+	assert.Empty(t, report.Threads[0].Frames[1].Locations)
+	assert.Contains(t, report.String(), "main + ")
+}
@@ -0,0 +1,429 @@
+package symbolic
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+)
+
+// ArtifactBundle is a zip of the minified sources and source maps of a JavaScript release,
+// described by a manifest.json:
+//
+//	{"files": {"files/_/_/main.js": {"url": "~/main.js", "type": "minified_source", "headers": {"debug-id": "...", "sourcemap": "main.js.map"}}}}
+//
+// SourceMapCaches are built on first use.
+type ArtifactBundle struct {
+	closer io.Closer
+
+	byURL     map[string]*artifactFile
+	byDebugID map[string][]*artifactFile
+	byName    map[string][]*artifactFile
+
+	mu     sync.Mutex
+	caches map[*artifactFile]*artifactCache
+	// cacheOpts are applied to every SourceMapCache of the bundle
+	cacheOpts []SourceMapCacheOption
+
+	// bundles are uploaded, the uncompressed size of their files is limited
+	maxFileSize  int64
+	maxTotalSize int64
+	totalSize    atomic.Int64
+}
+
+// artifactCache is the SourceMapCache of a file, waiters block on done while it is built
+type artifactCache struct {
+	done chan struct{}
+	smc  *SourceMapCache
+	err  error
+}
+
+// ErrArtifactTooLarge is returned when a file of an ArtifactBundle, or all files read from it, exceed the size limit
+var ErrArtifactTooLarge = errors.New("artifact bundle file exceeds the size limit")
+
+const (
+	// DefaultMaxArtifactSize is the default limit of the uncompressed size of a single file of an ArtifactBundle
+	DefaultMaxArtifactSize = 256 << 20
+	// DefaultMaxArtifactBundleSize is the default limit of the uncompressed size of all files read from an ArtifactBundle
+	DefaultMaxArtifactBundleSize = 2 << 30
+)
+
+// ArtifactBundleOption configures an ArtifactBundle
+type ArtifactBundleOption func(*ArtifactBundle)
+
+// WithArtifactSizeLimits limits the uncompressed size of a single file and of all files read from
+// the bundle, reads beyond them fail with ErrArtifactTooLarge. The defaults are DefaultMaxArtifactSize
+// and DefaultMaxArtifactBundleSize, a limit of 0 or less keeps the default.
+func WithArtifactSizeLimits(maxFileSize, maxTotalSize int64) ArtifactBundleOption {
+	return func(b *ArtifactBundle) {
+		if maxFileSize > 0 {
+			b.maxFileSize = maxFileSize
+		}
+		if maxTotalSize > 0 {
+			b.maxTotalSize = maxTotalSize
+		}
+	}
+}
+
+// WithArtifactSourceMapCacheOptions applies opts to every SourceMapCache built from the bundle
+func WithArtifactSourceMapCacheOptions(opts ...SourceMapCacheOption) ArtifactBundleOption {
+	return func(b *ArtifactBundle) {
+		b.cacheOpts = append(b.cacheOpts, opts...)
+	}
+}
+
+type artifactFile struct {
+	zf       *zip.File
+	url      string
+	fileType string
+	headers  map[string]string
+	debugID  string
+}
+
+type artifactManifest struct {
+	Files map[string]struct {
+		URL     string            `json:"url"`
+		Type    string            `json:"type"`
+		Headers map[string]string `json:"headers"`
+	} `json:"files"`
+}
+
+const (
+	artifactMinifiedSource = "minified_source"
+	artifactSourceMap      = "source_map"
+)
+
+// OpenArtifactBundle opens the artifact bundle zip at the given path, it has to be closed when done
+func OpenArtifactBundle(path string, opts ...ArtifactBundleOption) (*ArtifactBundle, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := newArtifactBundle(&r.Reader, opts)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	b.closer = r
+
+	return b, nil
+}
+
+// NewArtifactBundle reads an artifact bundle zip from r
+func NewArtifactBundle(r io.ReaderAt, size int64, opts ...ArtifactBundleOption) (*ArtifactBundle, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return newArtifactBundle(zr, opts)
+}
+
+func newArtifactBundle(zr *zip.Reader, opts []ArtifactBundleOption) (*ArtifactBundle, error) {
+	b := &ArtifactBundle{
+		byURL:        make(map[string]*artifactFile),
+		byDebugID:    make(map[string][]*artifactFile),
+		byName:       make(map[string][]*artifactFile),
+		caches:       make(map[*artifactFile]*artifactCache),
+		maxFileSize:  DefaultMaxArtifactSize,
+		maxTotalSize: DefaultMaxArtifactBundleSize,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+
+	mf, ok := entries["manifest.json"]
+	if !ok {
+		return nil, errors.New("artifact bundle has no manifest.json")
+	}
+
+	data, err := b.readZipFile(mf)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest artifactManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("reading manifest.json: %w", err)
+	}
+
+	for name, entry := range manifest.Files {
+		zf, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("manifest.json references missing file %s", name)
+		}
+
+		f := &artifactFile{
+			zf:       zf,
+			url:      entry.URL,
+			fileType: entry.Type,
+			headers:  make(map[string]string, len(entry.Headers)),
+		}
+		for k, v := range entry.Headers {
+			f.headers[strings.ToLower(k)] = v
+		}
+		f.debugID = normalizeDebugID(f.headers["debug-id"])
+
+		if f.url != "" {
+			b.byURL[f.url] = f
+			name := path.Base(f.url)
+			b.byName[name] = append(b.byName[name], f)
+		}
+		if f.debugID != "" {
+			b.byDebugID[f.debugID] = append(b.byDebugID[f.debugID], f)
+		}
+	}
+
+	return b, nil
+}
+
+// Close closes the zip file opened by OpenArtifactBundle
+func (b *ArtifactBundle) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+
+	return b.closer.Close()
+}
+
+// URLs lists the urls of all files in the bundle
+func (b *ArtifactBundle) URLs() []string {
+	urls := make([]string, 0, len(b.byURL))
+	for u := range b.byURL {
+		urls = append(urls, u)
+	}
+
+	return urls
+}
+
+// findURL matches a url against the bundle: exactly, without query and fragment,
+// and as a "~/" host agnostic path. Files are never matched by their name alone here,
+// which could resolve a url to an unrelated file, see SourceMapCacheForFileName.
+func (b *ArtifactBundle) findURL(rawURL string) *artifactFile {
+	if f, ok := b.byURL[rawURL]; ok {
+		return f
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	u.RawQuery = ""
+	u.Fragment = ""
+	if f, ok := b.byURL[u.String()]; ok {
+		return f
+	}
+
+	if f, ok := b.byURL["~"+u.EscapedPath()]; ok {
+		return f
+	}
+	if f, ok := b.byURL["~"+u.Path]; ok {
+		return f
+	}
+
+	return nil
+}
+
+// SourceMapCacheForURL returns the SourceMapCache of the minified source at url, or nil when the bundle does not contain it
+func (b *ArtifactBundle) SourceMapCacheForURL(rawURL string) (*SourceMapCache, error) {
+	f := b.findURL(rawURL)
+	if f == nil {
+		return nil, nil
+	}
+
+	return b.sourceMapCache(f)
+}
+
+// SourceMapCacheForDebugID returns the SourceMapCache for the debug ID, or nil when the bundle does not contain it
+func (b *ArtifactBundle) SourceMapCacheForDebugID(debugID string) (*SourceMapCache, error) {
+	files := b.byDebugID[normalizeDebugID(debugID)]
+
+	// prefer the minified source, a source map alone still resolves locations
+	for _, f := range files {
+		if f.fileType == artifactMinifiedSource {
+			return b.sourceMapCache(f)
+		}
+	}
+	for _, f := range files {
+		if f.fileType == artifactSourceMap {
+			return b.sourceMapCache(f)
+		}
+	}
+
+	return nil, nil
+}
+
+// SourceMapCacheForFileName returns the SourceMapCache of the file whose url ends in the file
+// name, e.g. "app.js", or nil when the bundle does not contain it. Unlike SourceMapCacheForURL
+// the host and directories are ignored, so an error is returned when several files share the name.
+func (b *ArtifactBundle) SourceMapCacheForFileName(name string) (*SourceMapCache, error) {
+	files := b.byName[path.Base(name)]
+	switch len(files) {
+	case 0:
+		return nil, nil
+	case 1:
+		return b.sourceMapCache(files[0])
+	}
+
+	urls := make([]string, len(files))
+	for i, f := range files {
+		urls[i] = f.url
+	}
+	sort.Strings(urls)
+
+	return nil, fmt.Errorf("file name %s matches several files: %s", name, strings.Join(urls, ", "))
+}
+
+// Resolver returns a SourceMapCacheResolver for SymbolicateJSStack that looks up scripts by url
+func (b *ArtifactBundle) Resolver() SourceMapCacheResolver {
+	return b.SourceMapCacheForURL
+}
+
+// sourceMapCache returns the cache of a file, building it on first use. Concurrent callers
+// for the same file wait for a single build, other files are built in parallel.
+func (b *ArtifactBundle) sourceMapCache(f *artifactFile) (*SourceMapCache, error) {
+	b.mu.Lock()
+	c, ok := b.caches[f]
+	if ok {
+		b.mu.Unlock()
+		<-c.done
+		return c.smc, c.err
+	}
+
+	c = &artifactCache{done: make(chan struct{})}
+	b.caches[f] = c
+	b.mu.Unlock()
+
+	c.smc, c.err = b.buildSourceMapCache(f)
+	close(c.done)
+
+	return c.smc, c.err
+}
+
+func (b *ArtifactBundle) buildSourceMapCache(f *artifactFile) (*SourceMapCache, error) {
+	switch f.fileType {
+	case artifactSourceMap:
+		sourceMap, err := b.readArtifact(f)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewSourceMapCacheFromSourceMap(string(sourceMap), b.cacheOpts...)
+	case artifactMinifiedSource:
+		source, err := b.readArtifact(f)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceMap, err := b.findSourceMap(f, string(source))
+		if err != nil {
+			return nil, err
+		}
+
+		return NewSourceMapCache(string(source), string(sourceMap), b.cacheOpts...)
+	}
+
+	return nil, fmt.Errorf("%s is a %s, not a minified source or source map", f.zf.Name, f.fileType)
+}
+
+// findSourceMap locates the source map of a minified source by its SourceMap header,
+// its debug ID or its sourceMappingURL comment
+func (b *ArtifactBundle) findSourceMap(f *artifactFile, source string) ([]byte, error) {
+	loader := func(ref string) ([]byte, error) {
+		m := b.findURL(ref)
+		if m == nil {
+			return nil, fmt.Errorf("source map %s is not in the artifact bundle", ref)
+		}
+		return b.readArtifact(m)
+	}
+
+	if ref := f.headers["sourcemap"]; ref != "" {
+		return LoadSourceMap(f.url, ref, loader)
+	}
+
+	for _, m := range b.byDebugID[f.debugID] {
+		if m.fileType == artifactSourceMap {
+			return b.readArtifact(m)
+		}
+	}
+
+	if ref := DiscoverSourceMapURL(source, nil); ref != "" {
+		return LoadSourceMap(f.url, ref, loader)
+	}
+
+	return nil, fmt.Errorf("no source map found for %s", f.url)
+}
+
+// readArtifact reads a file of the bundle, decompressing gzip and brotli compressed entries
+func (b *ArtifactBundle) readArtifact(f *artifactFile) ([]byte, error) {
+	data, err := b.readZipFile(f.zf)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := f.headers["content-encoding"]
+
+	switch {
+	case encoding == "gzip" || strings.HasSuffix(f.zf.Name, ".gz") || bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return b.readLimited(f.zf.Name, r)
+	case encoding == "br" || strings.HasSuffix(f.zf.Name, ".br"):
+		return b.readLimited(f.zf.Name, brotli.NewReader(bytes.NewReader(data)))
+	}
+
+	return data, nil
+}
+
+func (b *ArtifactBundle) readZipFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(b.maxFileSize) {
+		return nil, fmt.Errorf("%w: %s has %d bytes", ErrArtifactTooLarge, f.Name, f.UncompressedSize64)
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return b.readLimited(f.Name, r)
+}
+
+// readLimited reads r up to the size limits, the size in the zip header is not trusted and
+// decompressed entries can be far larger than their compressed data
+func (b *ArtifactBundle) readLimited(name string, r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, b.maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > b.maxFileSize {
+		return nil, fmt.Errorf("%w: %s has more than %d bytes", ErrArtifactTooLarge, name, b.maxFileSize)
+	}
+	if total := b.totalSize.Add(int64(len(data))); total > b.maxTotalSize {
+		return nil, fmt.Errorf("%w: reading %s exceeds %d bytes read from the bundle", ErrArtifactTooLarge, name, b.maxTotalSize)
+	}
+
+	return data, nil
+}
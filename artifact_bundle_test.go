@@ -0,0 +1,217 @@
+package symbolic
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestArtifactBundle(t *testing.T, files map[string][]byte) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, data := range files {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write(data)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, zw.Close())
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestArtifactBundle(t *testing.T) {
+	appMap := `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA"}`
+	vendorMap := `{"version":3,"sources":["src/vendor.js"],"names":[],"mappings":"AAAA"}`
+	chunkMap := `{"version":3,"sources":["src/chunk.js"],"names":[],"mappings":"AAAA"}`
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(vendorMap))
+	gw.Close()
+
+	var br bytes.Buffer
+	bw := brotli.NewWriter(&br)
+	bw.Write([]byte(chunkMap))
+	bw.Close()
+
+	manifest := `{"files": {
+		"files/_/_/app.js": {"url": "~/static/app.js", "type": "minified_source", "headers": {"Sourcemap": "app.js.map"}},
+		"files/_/_/app.js.map": {"url": "~/static/app.js.map", "type": "source_map"},
+		"files/_/_/vendor.4f2a.js": {"url": "~/static/vendor.4f2a.js", "type": "minified_source", "headers": {"debug-id": "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10"}},
+		"files/_/_/vendor.4f2a.js.map.gz": {"url": "~/static/vendor.4f2a.js.map", "type": "source_map", "headers": {"debug-id": "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10"}},
+		"files/_/_/chunk.js": {"url": "https://cdn.example.com/chunk.js", "type": "minified_source"},
+		"files/_/_/chunk.js.map.br": {"url": "https://cdn.example.com/chunk.js.map", "type": "source_map"}
+	}}`
+
+	r := writeTestArtifactBundle(t, map[string][]byte{
+		"manifest.json":                   []byte(manifest),
+		"files/_/_/app.js":                []byte("a();"),
+		"files/_/_/app.js.map":            []byte(appMap),
+		"files/_/_/vendor.4f2a.js":        []byte("v();\n//# debugId=b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10"),
+		"files/_/_/vendor.4f2a.js.map.gz": gz.Bytes(),
+		"files/_/_/chunk.js":              []byte("c();\n//# sourceMappingURL=chunk.js.map"),
+		"files/_/_/chunk.js.map.br":       br.Bytes(),
+	})
+
+	bundle, err := NewArtifactBundle(r, r.Size(), WithArtifactSourceMapCacheOptions(WithRetainedInputs()))
+	assert.NoError(t, err)
+	assert.Len(t, bundle.URLs(), 6)
+
+	for _, tc := range []struct {
+		url string
+		src string
+	}{
+		{"https://example.com/static/app.js", "src/app.js"},
+		{"http://localhost:8080/static/app.js?v=3#top", "src/app.js"},
+		{"https://example.com/static/vendor.4f2a.js", "src/vendor.js"},
+		{"https://cdn.example.com/chunk.js", "src/chunk.js"},
+	} {
+		smc, err := bundle.SourceMapCacheForURL(tc.url)
+		assert.NoError(t, err, tc.url)
+		assert.NotNil(t, smc, tc.url)
+
+		sources, err := smc.Sources()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{tc.src}, sources, tc.url)
+	}
+
+	smc, err := bundle.SourceMapCacheForURL("https://example.com/static/missing.js")
+	assert.NoError(t, err)
+	assert.Nil(t, smc)
+
+	// a url that only shares the file name is not matched
+	smc, err = bundle.SourceMapCacheForURL("https://other.example.com/assets/chunk.js")
+	assert.NoError(t, err)
+	assert.Nil(t, smc)
+
+	// unless it is looked up by file name explicitly
+	smc, err = bundle.SourceMapCacheForFileName("chunk.js")
+	assert.NoError(t, err)
+	if assert.NotNil(t, smc) {
+		sources, err := smc.Sources()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"src/chunk.js"}, sources)
+	}
+
+	smc, err = bundle.SourceMapCacheForFileName("missing.js")
+	assert.NoError(t, err)
+	assert.Nil(t, smc)
+
+	smc, err = bundle.SourceMapCacheForDebugID("B8A5C5A75C5E4C518F2B4C3B1A7E9D10")
+	assert.NoError(t, err)
+	assert.NotNil(t, smc)
+	assert.Equal(t, "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10", smc.DebugID())
+
+	// caches are only built once
+	again, err := bundle.SourceMapCacheForURL("https://example.com/static/vendor.4f2a.js")
+	assert.NoError(t, err)
+	assert.Same(t, smc, again)
+
+	_, err = bundle.SourceMapCacheForURL("https://example.com/static/app.js.map")
+	assert.NoError(t, err)
+}
+
+func TestArtifactBundleAmbiguousFileName(t *testing.T) {
+	sourceMap := `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA"}`
+
+	r := writeTestArtifactBundle(t, map[string][]byte{
+		"manifest.json": []byte(`{"files": {
+			"a/app.js.map": {"url": "~/a/app.js.map", "type": "source_map"},
+			"b/app.js.map": {"url": "~/b/app.js.map", "type": "source_map"}
+		}}`),
+		"a/app.js.map": []byte(sourceMap),
+		"b/app.js.map": []byte(sourceMap),
+	})
+
+	bundle, err := NewArtifactBundle(r, r.Size())
+	assert.NoError(t, err)
+
+	_, err = bundle.SourceMapCacheForFileName("app.js.map")
+	assert.ErrorContains(t, err, "~/a/app.js.map, ~/b/app.js.map")
+}
+
+func TestArtifactBundleWithoutManifest(t *testing.T) {
+	r := writeTestArtifactBundle(t, map[string][]byte{"app.js": []byte("a();")})
+
+	_, err := NewArtifactBundle(r, r.Size())
+	assert.Error(t, err)
+}
+
+func TestArtifactBundleSizeLimits(t *testing.T) {
+	sourceMap := `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA"}`
+
+	// a small gzip entry that decompresses far beyond the limit
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(sourceMap))
+	gw.Write([]byte(strings.Repeat(" ", 1<<20)))
+	gw.Close()
+
+	manifest := `{"files": {
+		"files/_/_/app.js.map": {"url": "~/app.js.map", "type": "source_map"},
+		"files/_/_/bomb.js.map.gz": {"url": "~/bomb.js.map", "type": "source_map"}
+	}}`
+
+	r := writeTestArtifactBundle(t, map[string][]byte{
+		"manifest.json":            []byte(manifest),
+		"files/_/_/app.js.map":     []byte(sourceMap),
+		"files/_/_/bomb.js.map.gz": gz.Bytes(),
+	})
+
+	bundle, err := NewArtifactBundle(r, r.Size(), WithArtifactSizeLimits(4096, 0))
+	assert.NoError(t, err)
+
+	_, err = bundle.SourceMapCacheForURL("https://example.com/bomb.js.map")
+	assert.ErrorIs(t, err, ErrArtifactTooLarge)
+
+	// the total limit covers every file read from the bundle
+	bundle, err = NewArtifactBundle(r, r.Size(), WithArtifactSizeLimits(0, int64(len(manifest)+len(sourceMap)-1)))
+	assert.NoError(t, err)
+
+	_, err = bundle.SourceMapCacheForURL("https://example.com/app.js.map")
+	assert.ErrorIs(t, err, ErrArtifactTooLarge)
+
+	// the manifest is limited as well
+	_, err = NewArtifactBundle(r, r.Size(), WithArtifactSizeLimits(16, 0))
+	assert.ErrorIs(t, err, ErrArtifactTooLarge)
+}
+
+func TestArtifactBundleConcurrentLookups(t *testing.T) {
+	sourceMap := `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA"}`
+
+	r := writeTestArtifactBundle(t, map[string][]byte{
+		"manifest.json":        []byte(`{"files": {"files/_/_/app.js.map": {"url": "~/app.js.map", "type": "source_map"}}}`),
+		"files/_/_/app.js.map": []byte(sourceMap),
+	})
+
+	bundle, err := NewArtifactBundle(r, r.Size())
+	assert.NoError(t, err)
+
+	caches := make([]*SourceMapCache, 8)
+	var wg sync.WaitGroup
+	for i := range caches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			smc, err := bundle.SourceMapCacheForURL("https://example.com/app.js.map")
+			assert.NoError(t, err)
+			caches[i] = smc
+		}(i)
+	}
+	wg.Wait()
+
+	// every caller gets the single cache built for the file
+	for _, smc := range caches {
+		assert.NotNil(t, smc)
+		assert.Same(t, caches[0], smc)
+	}
+}
@@ -0,0 +1,453 @@
+package symbolic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// V8ScriptCoverage is the coverage of one script as reported by V8, e.g. by Playwright's
+// page.coverage.stopJSCoverage() or NODE_V8_COVERAGE
+type V8ScriptCoverage struct {
+	URL      string `json:"url"`
+	ScriptID string `json:"scriptId"`
+	// Source is the script's source when the tool included it, otherwise the source of the SourceMapCache is used
+	Source    string               `json:"source,omitempty"`
+	Functions []V8FunctionCoverage `json:"functions"`
+}
+
+// V8FunctionCoverage holds the ranges of a function, the first range covers the whole function
+type V8FunctionCoverage struct {
+	FunctionName    string            `json:"functionName"`
+	Ranges          []V8CoverageRange `json:"ranges"`
+	IsBlockCoverage bool              `json:"isBlockCoverage"`
+}
+
+// V8CoverageRange is a range of UTF-16 offsets into the script and how often it was executed
+type V8CoverageRange struct {
+	StartOffset int `json:"startOffset"`
+	EndOffset   int `json:"endOffset"`
+	Count       int `json:"count"`
+}
+
+// Coverage is the coverage of original source files by path
+type Coverage map[string]*FileCoverage
+
+// FileCoverage is the coverage of one original source file
+type FileCoverage struct {
+	Path string
+	// Lines holds the execution count by 1-based line, the lowest count of the ranges on the line
+	Lines     map[int]int
+	Functions []FunctionCoverage
+	Branches  []BranchCoverage
+}
+
+// FunctionCoverage is how often a function starting at Position was called
+type FunctionCoverage struct {
+	Name     string
+	Position Position
+	Count    int
+}
+
+// BranchCoverage is how often the block starting at Position was executed
+type BranchCoverage struct {
+	Position Position
+	Count    int
+}
+
+// ConvertV8Coverage translates the V8 coverage of bundles into coverage of their original
+// sources, using the SourceMapCache the resolver returns for each script url. Both a plain
+// array of scripts and the {"result": [...]} object written by NODE_V8_COVERAGE are accepted.
+// Scripts the resolver has no cache for are skipped, as are scripts whose cache cannot convert
+// them: one created without WithRetainedInputs, or without the minified source when the
+// coverage does not include the script's source either.
+func ConvertV8Coverage(r io.Reader, resolver SourceMapCacheResolver) (Coverage, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts []V8ScriptCoverage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &scripts)
+	} else {
+		var wrapped struct {
+			Result []V8ScriptCoverage `json:"result"`
+		}
+		err = json.Unmarshal(trimmed, &wrapped)
+		scripts = wrapped.Result
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := make(Coverage)
+	for i := range scripts {
+		script := &scripts[i]
+		if script.URL == "" {
+			continue
+		}
+
+		smc, err := resolver(script.URL)
+		if err != nil {
+			return nil, err
+		}
+		if smc == nil {
+			continue
+		}
+
+		err = coverage.addScript(script, smc)
+		if errors.Is(err, ErrNoMinifiedSource) || errors.Is(err, ErrInputsNotRetained) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", script.URL, err)
+		}
+	}
+
+	return coverage, nil
+}
+
+func (c Coverage) file(path string) *FileCoverage {
+	f, ok := c[path]
+	if !ok {
+		f = &FileCoverage{Path: path, Lines: make(map[int]int)}
+		c[path] = f
+	}
+
+	return f
+}
+
+func (c Coverage) addScript(script *V8ScriptCoverage, smc *SourceMapCache) error {
+	// offsets can only be converted to positions with the script's source
+	source := script.Source
+	if source == "" {
+		var err error
+		if source, err = smc.minifiedSource(); err != nil {
+			return err
+		}
+	}
+
+	lines := newUTF16LineIndex(source)
+
+	var ranges []V8CoverageRange
+	for _, fn := range script.Functions {
+		ranges = append(ranges, fn.Ranges...)
+	}
+
+	// outer ranges first, V8 ranges are either nested or disjoint
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].StartOffset != ranges[j].StartOffset {
+			return ranges[i].StartOffset < ranges[j].StartOffset
+		}
+		return ranges[i].EndOffset > ranges[j].EndOffset
+	})
+
+	it, err := smc.Mappings()
+	if err != nil {
+		return err
+	}
+
+	// mappings come in generated order, sweep over them keeping a stack of the ranges containing the offset
+	var stack []V8CoverageRange
+	next := 0
+
+	for it.Next() {
+		m := it.Mapping()
+		if !m.HasSource {
+			continue
+		}
+
+		offset := lines.offset(m.Generated)
+
+		for next < len(ranges) && ranges[next].StartOffset <= offset {
+			for len(stack) > 0 && stack[len(stack)-1].EndOffset <= ranges[next].StartOffset {
+				stack = stack[:len(stack)-1]
+			}
+			stack = append(stack, ranges[next])
+			next++
+		}
+		for len(stack) > 0 && stack[len(stack)-1].EndOffset <= offset {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) == 0 {
+			continue
+		}
+
+		f := c.file(smc.tokenSrc(it.sourceRoot(), m.Source))
+		line, _ := m.Original.OneBased()
+		count := stack[len(stack)-1].Count
+		// a line is only as covered as its least executed part
+		if current, ok := f.Lines[int(line)]; !ok || count < current {
+			f.Lines[int(line)] = count
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	return c.addFunctionsAndBranches(script, smc, lines)
+}
+
+// addFunctionsAndBranches looks up the start of every function and block in one batch
+func (c Coverage) addFunctionsAndBranches(script *V8ScriptCoverage, smc *SourceMapCache, lines *utf16LineIndex) error {
+	type start struct {
+		function string
+		isBranch bool
+		count    int
+	}
+
+	var starts []start
+	var positions []Position
+
+	for _, fn := range script.Functions {
+		for i, r := range fn.Ranges {
+			if i > 0 && !fn.IsBlockCoverage {
+				break
+			}
+
+			starts = append(starts, start{function: fn.FunctionName, isBranch: i > 0, count: r.Count})
+			positions = append(positions, lines.position(r.StartOffset))
+		}
+	}
+
+	tokens, errs := smc.LookupMany(positions, 0)
+
+	for i, token := range tokens {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if token == nil || token.Src == "" {
+			continue
+		}
+
+		// tokens carry the source as tokenSrc derives it for the mappings above
+		f := c.file(token.Src)
+
+		if starts[i].isBranch {
+			f.Branches = append(f.Branches, BranchCoverage{Position: token.Position, Count: starts[i].count})
+			continue
+		}
+
+		name := token.FunctionName
+		if name == "" || name == "<anonymous>" {
+			name = token.Name
+		}
+		if name == "" {
+			name = starts[i].function
+		}
+
+		f.Functions = append(f.Functions, FunctionCoverage{Name: name, Position: token.Position, Count: starts[i].count})
+	}
+
+	return nil
+}
+
+// utf16LineIndex converts between positions and the UTF-16 offsets V8 and source maps use
+type utf16LineIndex struct {
+	starts []int
+}
+
+func newUTF16LineIndex(source string) *utf16LineIndex {
+	starts := []int{0}
+	offset := 0
+
+	for i := 0; i < len(source); {
+		r, size := utf8.DecodeRuneInString(source[i:])
+		i += size
+
+		if r >= 0x10000 {
+			offset += 2
+		} else {
+			offset++
+		}
+
+		if r == '\n' {
+			starts = append(starts, offset)
+		}
+	}
+
+	return &utf16LineIndex{starts: starts}
+}
+
+func (l *utf16LineIndex) offset(pos Position) int {
+	line, col := pos.ZeroBased()
+	if int(line) >= len(l.starts) {
+		return l.starts[len(l.starts)-1] + int(col)
+	}
+
+	return l.starts[line] + int(col)
+}
+
+func (l *utf16LineIndex) position(offset int) Position {
+	line := sort.Search(len(l.starts), func(i int) bool {
+		return l.starts[i] > offset
+	}) - 1
+	if line < 0 {
+		line = 0
+	}
+
+	return ZeroBased(uint32(line), uint32(offset-l.starts[line]))
+}
+
+func (c Coverage) paths() []string {
+	paths := make([]string, 0, len(c))
+	for p := range c {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func (f *FileCoverage) sortedLines() []int {
+	lines := make([]int, 0, len(f.Lines))
+	for l := range f.Lines {
+		lines = append(lines, l)
+	}
+	sort.Ints(lines)
+
+	return lines
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile
+func (c Coverage) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, path := range c.paths() {
+		f := c[path]
+
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", f.Path)
+
+		hit := 0
+		for _, fn := range f.Functions {
+			line, _ := fn.Position.OneBased()
+			fmt.Fprintf(bw, "FN:%d,%s\n", line, fn.Name)
+		}
+		for _, fn := range f.Functions {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", fn.Count, fn.Name)
+			if fn.Count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(f.Functions), hit)
+
+		hit = 0
+		for i, b := range f.Branches {
+			line, _ := b.Position.OneBased()
+			taken := "-"
+			if b.Count > 0 {
+				taken = strconv.Itoa(b.Count)
+				hit++
+			}
+			fmt.Fprintf(bw, "BRDA:%d,%d,0,%s\n", line, i, taken)
+		}
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", len(f.Branches), hit)
+
+		hit = 0
+		for _, line := range f.sortedLines() {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, f.Lines[line])
+			if f.Lines[line] > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(f.Lines), hit)
+	}
+
+	return bw.Flush()
+}
+
+type istanbulLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type istanbulRange struct {
+	Start istanbulLocation `json:"start"`
+	End   istanbulLocation `json:"end"`
+}
+
+type istanbulFunction struct {
+	Name string        `json:"name"`
+	Decl istanbulRange `json:"decl"`
+	Loc  istanbulRange `json:"loc"`
+	Line int           `json:"line"`
+}
+
+type istanbulBranch struct {
+	Loc       istanbulRange   `json:"loc"`
+	Type      string          `json:"type"`
+	Locations []istanbulRange `json:"locations"`
+	Line      int             `json:"line"`
+}
+
+type istanbulFileCoverage struct {
+	Path         string                      `json:"path"`
+	StatementMap map[string]istanbulRange    `json:"statementMap"`
+	FnMap        map[string]istanbulFunction `json:"fnMap"`
+	BranchMap    map[string]istanbulBranch   `json:"branchMap"`
+	S            map[string]int              `json:"s"`
+	F            map[string]int              `json:"f"`
+	B            map[string][]int            `json:"b"`
+}
+
+func istanbulPoint(pos Position) istanbulRange {
+	// istanbul lines are 1-based and columns 0-based
+	line, _ := pos.OneBased()
+	_, col := pos.ZeroBased()
+	loc := istanbulLocation{Line: int(line), Column: int(col)}
+
+	return istanbulRange{Start: loc, End: loc}
+}
+
+// WriteIstanbul writes the coverage in the istanbul coverage-final.json format, with one statement per line
+func (c Coverage) WriteIstanbul(w io.Writer) error {
+	out := make(map[string]*istanbulFileCoverage, len(c))
+
+	for _, path := range c.paths() {
+		f := c[path]
+		ic := &istanbulFileCoverage{
+			Path:         f.Path,
+			StatementMap: make(map[string]istanbulRange),
+			FnMap:        make(map[string]istanbulFunction),
+			BranchMap:    make(map[string]istanbulBranch),
+			S:            make(map[string]int),
+			F:            make(map[string]int),
+			B:            make(map[string][]int),
+		}
+
+		for i, line := range f.sortedLines() {
+			id := strconv.Itoa(i)
+			ic.StatementMap[id] = istanbulRange{
+				Start: istanbulLocation{Line: line, Column: 0},
+				End:   istanbulLocation{Line: line, Column: 0},
+			}
+			ic.S[id] = f.Lines[line]
+		}
+
+		for i, fn := range f.Functions {
+			id := strconv.Itoa(i)
+			loc := istanbulPoint(fn.Position)
+			ic.FnMap[id] = istanbulFunction{Name: fn.Name, Decl: loc, Loc: loc, Line: loc.Start.Line}
+			ic.F[id] = fn.Count
+		}
+
+		for i, b := range f.Branches {
+			id := strconv.Itoa(i)
+			loc := istanbulPoint(b.Position)
+			ic.BranchMap[id] = istanbulBranch{Loc: loc, Type: "branch", Locations: []istanbulRange{loc}, Line: loc.Start.Line}
+			ic.B[id] = []int{b.Count}
+		}
+
+		out[path] = ic
+	}
+
+	return json.NewEncoder(w).Encode(out)
+}
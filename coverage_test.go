@@ -0,0 +1,166 @@
+package symbolic
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testV8Coverage = `[
+	{"url": "http://example.com/app.js", "scriptId": "7", "functions": [
+		{"functionName": "", "isBlockCoverage": true, "ranges": [
+			{"startOffset": 0, "endOffset": 9, "count": 1},
+			{"startOffset": 5, "endOffset": 9, "count": 0}
+		]}
+	]},
+	{"url": "http://example.com/other.js", "scriptId": "8", "functions": []}
+]`
+
+func TestConvertV8Coverage(t *testing.T) {
+	smc, err := NewSourceMapCache("a();\nb();", `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA;AACA"}`, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	resolver := func(url string) (*SourceMapCache, error) {
+		if url == "http://example.com/app.js" {
+			return smc, nil
+		}
+		return nil, nil
+	}
+
+	for _, input := range []string{testV8Coverage, `{"result": ` + testV8Coverage + `}`} {
+		coverage, err := ConvertV8Coverage(strings.NewReader(input), resolver)
+		assert.NoError(t, err)
+
+		assert.Len(t, coverage, 1)
+		file := coverage["src/app.js"]
+		if assert.NotNil(t, file) {
+			assert.Equal(t, map[int]int{1: 1, 2: 0}, file.Lines)
+			assert.Equal(t, []FunctionCoverage{{Position: OneBased(1, 1), Count: 1}}, file.Functions)
+			assert.Equal(t, []BranchCoverage{{Position: OneBased(2, 1), Count: 0}}, file.Branches)
+		}
+	}
+}
+
+func TestConvertV8CoverageSourceRoot(t *testing.T) {
+	// both mappings are on the first line, the second one in a block that never ran
+	smc, err := NewSourceMapCache("a();b();", `{"version":3,"sourceRoot":"src/","sources":["app.js"],"names":[],"mappings":"AAAA,IAAI"}`, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	coverage, err := ConvertV8Coverage(strings.NewReader(`[{"url": "app.js", "scriptId": "1", "functions": [
+		{"functionName": "", "isBlockCoverage": true, "ranges": [
+			{"startOffset": 0, "endOffset": 8, "count": 1},
+			{"startOffset": 4, "endOffset": 8, "count": 0}
+		]}
+	]}]`), func(string) (*SourceMapCache, error) { return smc, nil })
+	assert.NoError(t, err)
+
+	// lines, functions and branches end up in the same file
+	assert.Len(t, coverage, 1)
+	file := coverage["src/app.js"]
+	if assert.NotNil(t, file) {
+		assert.Equal(t, map[int]int{1: 0}, file.Lines)
+		assert.Len(t, file.Functions, 1)
+		assert.Len(t, file.Branches, 1)
+	}
+}
+
+func TestConvertV8CoverageWithoutSource(t *testing.T) {
+	sourceMap := `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA;AACA"}`
+
+	smc, err := NewSourceMapCacheFromSourceMap(sourceMap, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	resolver := func(url string) (*SourceMapCache, error) {
+		return smc, nil
+	}
+
+	// scripts the cache has no minified source for are skipped
+	coverage, err := ConvertV8Coverage(strings.NewReader(testV8Coverage), resolver)
+	assert.NoError(t, err)
+	assert.Empty(t, coverage)
+
+	// unless the coverage includes the source
+	withSource := `[{"url": "http://example.com/app.js", "scriptId": "7", "source": "a();\nb();", "functions": [
+		{"functionName": "", "isBlockCoverage": true, "ranges": [{"startOffset": 0, "endOffset": 9, "count": 1}]}
+	]}]`
+	coverage, err = ConvertV8Coverage(strings.NewReader(withSource), resolver)
+	assert.NoError(t, err)
+	if assert.NotNil(t, coverage["src/app.js"]) {
+		assert.Equal(t, map[int]int{1: 1, 2: 1}, coverage["src/app.js"].Lines)
+	}
+
+	// a cache without its inputs cannot convert any script
+	smc, err = NewSourceMapCacheFromSourceMap(sourceMap)
+	assert.NoError(t, err)
+
+	coverage, err = ConvertV8Coverage(strings.NewReader(withSource), resolver)
+	assert.NoError(t, err)
+	assert.Empty(t, coverage)
+}
+
+func TestUTF16LineIndex(t *testing.T) {
+	// the emoji takes two UTF-16 code units
+	lines := newUTF16LineIndex("a\n😀b\nc")
+
+	assert.Equal(t, 4, lines.offset(ZeroBased(1, 2)))
+	assert.Equal(t, ZeroBased(1, 2), lines.position(4))
+	assert.Equal(t, ZeroBased(2, 0), lines.position(6))
+}
+
+func testCoverage() Coverage {
+	return Coverage{
+		"src/app.js": {
+			Path:      "src/app.js",
+			Lines:     map[int]int{2: 0, 1: 3},
+			Functions: []FunctionCoverage{{Name: "main", Position: OneBased(1, 1), Count: 1}},
+			Branches:  []BranchCoverage{{Position: OneBased(2, 3), Count: 0}},
+		},
+	}
+}
+
+func TestCoverageWriteLCOV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, testCoverage().WriteLCOV(&buf))
+
+	assert.Equal(t, `TN:
+SF:src/app.js
+FN:1,main
+FNDA:1,main
+FNF:1
+FNH:1
+BRDA:2,0,0,-
+BRF:1
+BRH:0
+DA:1,3
+DA:2,0
+LF:2
+LH:1
+end_of_record
+`, buf.String())
+}
+
+func TestCoverageWriteIstanbul(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, testCoverage().WriteIstanbul(&buf))
+
+	var out map[string]struct {
+		Path         string                    `json:"path"`
+		StatementMap map[string]istanbulRange  `json:"statementMap"`
+		BranchMap    map[string]istanbulBranch `json:"branchMap"`
+		S            map[string]int            `json:"s"`
+		F            map[string]int            `json:"f"`
+		B            map[string][]int          `json:"b"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+
+	file := out["src/app.js"]
+	assert.Equal(t, "src/app.js", file.Path)
+	assert.Equal(t, 2, file.StatementMap["1"].Start.Line)
+	assert.Equal(t, map[string]int{"0": 3, "1": 0}, file.S)
+	assert.Equal(t, map[string]int{"0": 1}, file.F)
+	assert.Equal(t, map[string][]int{"0": {0}}, file.B)
+	assert.Equal(t, 2, file.BranchMap["0"].Loc.Start.Column)
+}
@@ -0,0 +1,236 @@
+package symbolic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// CPUProfile is a Chrome DevTools / V8 .cpuprofile, it marshals back into the same JSON format
+type CPUProfile struct {
+	Nodes      []*CPUProfileNode `json:"nodes"`
+	StartTime  int64             `json:"startTime"`
+	EndTime    int64             `json:"endTime"`
+	Samples    []int             `json:"samples,omitempty"`
+	TimeDeltas []int64           `json:"timeDeltas,omitempty"`
+}
+
+// CPUProfileNode is a node of the profile's call tree
+type CPUProfileNode struct {
+	ID            int                  `json:"id"`
+	CallFrame     CPUProfileCallFrame  `json:"callFrame"`
+	HitCount      int                  `json:"hitCount"`
+	Children      []int                `json:"children,omitempty"`
+	DeoptReason   string               `json:"deoptReason,omitempty"`
+	PositionTicks []CPUProfileLineTick `json:"positionTicks,omitempty"`
+}
+
+// CPUProfileCallFrame is the function a node was executing, line and column are 0-based
+type CPUProfileCallFrame struct {
+	FunctionName string `json:"functionName"`
+	ScriptID     string `json:"scriptId"`
+	URL          string `json:"url"`
+	LineNumber   int    `json:"lineNumber"`
+	ColumnNumber int    `json:"columnNumber"`
+}
+
+// CPUProfileLineTick counts the samples hitting a 1-based line of the node's function
+type CPUProfileLineTick struct {
+	Line  int `json:"line"`
+	Ticks int `json:"ticks"`
+}
+
+// RemapCPUProfile reads a .cpuprofile and rewrites every call frame to its original function
+// name and location using the SourceMapCache the resolver returns for the frame's url.
+// The scriptId of a remapped frame is set to "0", it referred to the minified script.
+// Frames whose url cannot be resolved or looked up are kept as they are, their errors are joined
+// and returned together with the profile, so err may be non-nil next to a usable profile.
+// Sibling nodes that end up with the same original frame are merged. Marshal the result with
+// encoding/json to write a .cpuprofile again.
+func RemapCPUProfile(profile io.Reader, resolver SourceMapCacheResolver) (*CPUProfile, error) {
+	var p CPUProfile
+	if err := json.NewDecoder(profile).Decode(&p); err != nil {
+		return nil, err
+	}
+
+	if len(p.Nodes) == 0 {
+		return &p, nil
+	}
+
+	var errs []error
+	caches := make(map[string]*SourceMapCache)
+	for _, node := range p.Nodes {
+		if err := remapCPUProfileNode(node, resolver, caches); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	mergeCPUProfileNodes(&p)
+
+	return &p, errors.Join(errs...)
+}
+
+// remapCPUProfileNode rewrites the call frame and line ticks of a node, it is left unchanged
+// when the frame's script has no cache or a lookup fails
+func remapCPUProfileNode(node *CPUProfileNode, resolver SourceMapCacheResolver, caches map[string]*SourceMapCache) error {
+	frame := &node.CallFrame
+	if resolver == nil || frame.URL == "" || frame.LineNumber < 0 || frame.ColumnNumber < 0 {
+		return nil
+	}
+
+	smc, ok := caches[frame.URL]
+	if !ok {
+		// a script that fails to resolve is not tried again, and its error not reported again, for its other frames
+		var err error
+		smc, err = resolver(frame.URL)
+		caches[frame.URL] = smc
+		if err != nil {
+			return fmt.Errorf("%s: %w", frame.URL, err)
+		}
+	}
+
+	if smc == nil {
+		return nil
+	}
+
+	token, err := smc.Lookup(ZeroBased(uint32(frame.LineNumber), uint32(frame.ColumnNumber)), 0)
+	if err != nil {
+		return fmt.Errorf("%s:%d:%d: %w", frame.URL, frame.LineNumber, frame.ColumnNumber, err)
+	}
+	if token == nil {
+		return nil
+	}
+
+	// the line ticks only carry a line. Minified functions usually start in the middle of their
+	// line, so the function's first line is looked up at the frame's column, later lines at their start.
+	ticks := make(map[int]int)
+	for _, tick := range node.PositionTicks {
+		pos := OneBased(uint32(tick.Line), 1)
+		if tick.Line == frame.LineNumber+1 {
+			pos = ZeroBased(uint32(frame.LineNumber), uint32(frame.ColumnNumber))
+		}
+
+		t, err := smc.Lookup(pos, 0)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", frame.URL, tick.Line, err)
+		}
+		if t == nil || t.Src != token.Src {
+			continue
+		}
+		line, _ := t.Position.OneBased()
+		ticks[int(line)] += tick.Ticks
+	}
+	node.PositionTicks = sortedLineTicks(ticks)
+
+	if token.FunctionName != "" {
+		frame.FunctionName = token.FunctionName
+	}
+	frame.URL = token.Src
+	frame.ScriptID = "0"
+	line, col := token.Position.ZeroBased()
+	frame.LineNumber = int(line)
+	frame.ColumnNumber = int(col)
+
+	return nil
+}
+
+func sortedLineTicks(ticks map[int]int) []CPUProfileLineTick {
+	if len(ticks) == 0 {
+		return nil
+	}
+
+	result := make([]CPUProfileLineTick, 0, len(ticks))
+	for line, count := range ticks {
+		result = append(result, CPUProfileLineTick{Line: line, Ticks: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Line < result[j].Line
+	})
+
+	return result
+}
+
+// mergeCPUProfileNodes merges siblings with the same call frame and drops the merged nodes from the profile
+func mergeCPUProfileNodes(p *CPUProfile) {
+	nodes := make(map[int]*CPUProfileNode, len(p.Nodes))
+	isChild := make(map[int]bool)
+	for _, node := range p.Nodes {
+		nodes[node.ID] = node
+		for _, c := range node.Children {
+			isChild[c] = true
+		}
+	}
+
+	// merged maps the id of every merged node to the node it was merged into
+	merged := make(map[int]int)
+	var kept []*CPUProfileNode
+
+	var merge func(node *CPUProfileNode)
+	merge = func(node *CPUProfileNode) {
+		kept = append(kept, node)
+
+		survivors := make(map[CPUProfileCallFrame]*CPUProfileNode)
+		var children []int
+
+		for _, id := range node.Children {
+			child, ok := nodes[id]
+			if !ok {
+				continue
+			}
+
+			survivor, ok := survivors[child.CallFrame]
+			if !ok {
+				survivors[child.CallFrame] = child
+				children = append(children, id)
+				continue
+			}
+
+			survivor.HitCount += child.HitCount
+			survivor.Children = append(survivor.Children, child.Children...)
+			survivor.PositionTicks = mergeLineTicks(survivor.PositionTicks, child.PositionTicks)
+			merged[id] = survivor.ID
+		}
+
+		node.Children = children
+		for _, id := range children {
+			merge(nodes[id])
+		}
+	}
+
+	for _, node := range p.Nodes {
+		if !isChild[node.ID] {
+			merge(node)
+		}
+	}
+
+	p.Nodes = kept
+
+	for i, id := range p.Samples {
+		for {
+			to, ok := merged[id]
+			if !ok {
+				break
+			}
+			id = to
+		}
+		p.Samples[i] = id
+	}
+}
+
+func mergeLineTicks(a, b []CPUProfileLineTick) []CPUProfileLineTick {
+	if len(b) == 0 {
+		return a
+	}
+
+	ticks := make(map[int]int)
+	for _, t := range a {
+		ticks[t.Line] += t.Ticks
+	}
+	for _, t := range b {
+		ticks[t.Line] += t.Ticks
+	}
+
+	return sortedLineTicks(ticks)
+}
@@ -0,0 +1,121 @@
+package symbolic
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCPUProfile = `{
+	"nodes": [
+		{"id": 1, "callFrame": {"functionName": "(root)", "scriptId": "0", "url": "", "lineNumber": -1, "columnNumber": -1}, "hitCount": 0, "children": [2, 3, 4]},
+		{"id": 2, "callFrame": {"functionName": "a", "scriptId": "7", "url": "http://example.com/app.js", "lineNumber": 0, "columnNumber": 0}, "hitCount": 3, "children": [5]},
+		{"id": 3, "callFrame": {"functionName": "b", "scriptId": "7", "url": "http://example.com/app.js", "lineNumber": 0, "columnNumber": 4}, "hitCount": 2},
+		{"id": 4, "callFrame": {"functionName": "(garbage collector)", "scriptId": "0", "url": "", "lineNumber": -1, "columnNumber": -1}, "hitCount": 1},
+		{"id": 5, "callFrame": {"functionName": "c", "scriptId": "8", "url": "http://example.com/other.js", "lineNumber": 3, "columnNumber": 2}, "hitCount": 4}
+	],
+	"startTime": 100,
+	"endTime": 200,
+	"samples": [2, 3, 3, 5, 4],
+	"timeDeltas": [10, 10, 10, 10, 10]
+}`
+
+func TestRemapCPUProfile(t *testing.T) {
+	// both a() and b() map to the start of src/app.js
+	smc, err := NewSourceMapCache("a();b();", `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA,IAAA"}`)
+	assert.NoError(t, err)
+
+	resolver := func(url string) (*SourceMapCache, error) {
+		if url == "http://example.com/app.js" {
+			return smc, nil
+		}
+		return nil, nil
+	}
+
+	profile, err := RemapCPUProfile(strings.NewReader(testCPUProfile), resolver)
+	assert.NoError(t, err)
+
+	assert.Len(t, profile.Nodes, 4)
+	assert.Equal(t, []int{2, 4}, profile.Nodes[0].Children)
+
+	remapped := profile.Nodes[1]
+	assert.Equal(t, 2, remapped.ID)
+	assert.Equal(t, "src/app.js", remapped.CallFrame.URL)
+	assert.Equal(t, 0, remapped.CallFrame.LineNumber)
+	assert.Equal(t, 0, remapped.CallFrame.ColumnNumber)
+	assert.Equal(t, "0", remapped.CallFrame.ScriptID)
+	assert.Equal(t, 5, remapped.HitCount)
+	assert.Equal(t, []int{5}, remapped.Children)
+
+	// scripts without a source map are left alone
+	assert.Equal(t, "http://example.com/other.js", profile.Nodes[2].CallFrame.URL)
+	assert.Equal(t, "c", profile.Nodes[2].CallFrame.FunctionName)
+	assert.Equal(t, "8", profile.Nodes[2].CallFrame.ScriptID)
+
+	assert.Equal(t, []int{2, 2, 2, 5, 4}, profile.Samples)
+	assert.Equal(t, []int64{10, 10, 10, 10, 10}, profile.TimeDeltas)
+
+	out, err := json.Marshal(profile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `"timeDeltas":[10,10,10,10,10]`)
+}
+
+func TestRemapCPUProfileLineTicks(t *testing.T) {
+	// a() maps to line 1 and b() to line 6 of src/app.js, both start on the first minified line
+	smc, err := NewSourceMapCache("function a(){}function b(){}", `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA,cAKA"}`)
+	assert.NoError(t, err)
+
+	profile, err := RemapCPUProfile(strings.NewReader(`{
+		"nodes": [
+			{"id": 1, "callFrame": {"functionName": "(root)", "scriptId": "0", "url": "", "lineNumber": -1, "columnNumber": -1}, "children": [2]},
+			{"id": 2, "callFrame": {"functionName": "b", "scriptId": "1", "url": "app.js", "lineNumber": 0, "columnNumber": 14}, "hitCount": 2, "positionTicks": [{"line": 1, "ticks": 2}]}
+		],
+		"startTime": 0,
+		"endTime": 10
+	}`), func(string) (*SourceMapCache, error) { return smc, nil })
+	assert.NoError(t, err)
+
+	assert.Equal(t, 5, profile.Nodes[1].CallFrame.LineNumber)
+	assert.Equal(t, []CPUProfileLineTick{{Line: 6, Ticks: 2}}, profile.Nodes[1].PositionTicks)
+}
+
+func TestRemapCPUProfileResolverError(t *testing.T) {
+	profile, err := RemapCPUProfile(strings.NewReader(testCPUProfile), func(string) (*SourceMapCache, error) {
+		return nil, errors.New("unreachable")
+	})
+	// every script that fails to resolve is reported once
+	assert.EqualError(t, err, "http://example.com/app.js: unreachable\nhttp://example.com/other.js: unreachable")
+
+	// frames of scripts that fail to resolve are kept as they are
+	assert.NotNil(t, profile)
+	assert.Len(t, profile.Nodes, 5)
+	assert.Equal(t, "http://example.com/app.js", profile.Nodes[1].CallFrame.URL)
+	assert.Equal(t, "a", profile.Nodes[1].CallFrame.FunctionName)
+	assert.Equal(t, "7", profile.Nodes[1].CallFrame.ScriptID)
+}
+
+func TestRemapCPUProfileMergesIdenticalSiblings(t *testing.T) {
+	profile, err := RemapCPUProfile(strings.NewReader(`{
+		"nodes": [
+			{"id": 1, "callFrame": {"functionName": "(root)", "scriptId": "0", "url": "", "lineNumber": -1, "columnNumber": -1}, "children": [2, 3]},
+			{"id": 2, "callFrame": {"functionName": "a", "scriptId": "1", "url": "app.js", "lineNumber": 0, "columnNumber": 0}, "hitCount": 1, "positionTicks": [{"line": 1, "ticks": 1}]},
+			{"id": 3, "callFrame": {"functionName": "a", "scriptId": "1", "url": "app.js", "lineNumber": 0, "columnNumber": 0}, "hitCount": 2, "positionTicks": [{"line": 1, "ticks": 2}]}
+		],
+		"startTime": 0,
+		"endTime": 10,
+		"samples": [3, 2],
+		"timeDeltas": [1, 1]
+	}`), nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, profile.Nodes, 2)
+	assert.Equal(t, 3, profile.Nodes[1].HitCount)
+	assert.Equal(t, []CPUProfileLineTick{{Line: 1, Ticks: 3}}, profile.Nodes[1].PositionTicks)
+	assert.Equal(t, []int{2, 2}, profile.Samples)
+
+	_, err = RemapCPUProfile(strings.NewReader("not json"), nil)
+	assert.Error(t, err)
+}
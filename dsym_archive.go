@@ -7,36 +7,124 @@ package symbolic
 */
 import "C"
 import (
+	"errors"
+	"fmt"
 	"runtime"
+	"sync"
 	"unsafe"
 )
 
 // Archive represents a potential multi arch object archive (like a dSYM)
 type Archive struct {
 	archive *C.SymbolicArchive
+	// SymCaches holds the SymCache of every usable object by debug ID. It stays empty for
+	// archives loaded with WithoutSymCaches or WithLazySymCaches.
 	SymCaches map[string]*SymCache
+	// Errors lists the objects that could not be read or whose SymCache failed to build,
+	// loading only fails when no object is usable. Failed lazy builds are added by
+	// Archive.SymCache, read Errors once no builds are running.
+	Errors []*ObjectError
+
+	skipSymCaches bool
+	lazy          bool
+	workers       int
+
+	mu        sync.Mutex
+	byDebugID map[string]*Object
+	builds    map[string]*symCacheBuild
+
+	// data is the C copy of the bytes an archive loaded from memory reads from
+	data unsafe.Pointer
+}
+
+// ArchiveOption configures how an Archive is loaded
+type ArchiveOption func(*Archive)
+
+// WithoutSymCaches loads the archive without building SymCaches, for inspecting its objects
+func WithoutSymCaches() ArchiveOption {
+	return func(a *Archive) {
+		a.skipSymCaches = true
+	}
 }
 
 func (a *Archive) buildSymCaches() error {
 	a.SymCaches = make(map[string]*SymCache)
-	objects, err := a.objects()
+	if a.skipSymCaches {
+		return nil
+	}
+
+	objects, errs, err := a.readObjects()
 	if (err != nil) {
 		return err
 	}
+	a.Errors = append(a.Errors, errs...)
+
+	if a.lazy {
+		a.indexObjects(objects)
+		return a.checkUsable(len(objects))
+	}
+
+	if a.workers > 1 {
+		a.buildSymCachesParallel(objects)
+		return a.checkUsable(len(a.SymCaches))
+	}
 
 	for _,obj := range objects {
-		symCache, err := NewSymCacheFromObject(&obj)
+		symCache, err := buildSymCache(obj)
 		if err != nil {
-			return err
+			a.Errors = append(a.Errors, newObjectError(obj, err))
+			continue
 		}
 
 		a.SymCaches[symCache.debugId] = symCache
 	}
 
-	return nil
+	return a.checkUsable(len(a.SymCaches))
+}
+
+// readObjects reads every object of the archive, the ones that fail are returned as ObjectErrors
+func (a *Archive) readObjects() ([]*Object, []*ObjectError, error) {
+	count, err := a.ObjectCount()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var objects []*Object
+	var errs []*ObjectError
+	for i := 0; i < count; i++ {
+		obj, err := a.Object(i)
+		if err != nil {
+			errs = append(errs, &ObjectError{Index: i, Err: err})
+			continue
+		}
+		if obj != nil {
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, errs, nil
 }
 
-func (a *Archive) getObject(index int) (*Object, error) {
+// checkUsable fails when objects failed and none is left to use
+func (a *Archive) checkUsable(usable int) error {
+	if usable > 0 || len(a.Errors) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("no usable object in archive: %w", joinObjectErrors(a.Errors))
+}
+
+func joinObjectErrors(objErrs []*ObjectError) error {
+	errs := make([]error, len(objErrs))
+	for i, err := range objErrs {
+		errs[i] = err
+	}
+
+	return errors.Join(errs...)
+}
+
+// Object returns the object at index, or nil when there is none
+func (a *Archive) Object(index int) (*Object, error) {
 	C.symbolic_err_clear()
 	obj := C.symbolic_archive_get_object(a.archive, C.uintptr_t(index))
 	err := checkErr()
@@ -49,10 +137,18 @@ func (a *Archive) getObject(index int) (*Object, error) {
 		return nil, nil
 	}
 
-	return makeObject(obj)
+	goObj, err := makeObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	goObj.index = index
+	goObj.archive = a
+
+	return goObj, nil
 }
 
-func (a *Archive) objectCount() (int, error) {
+// ObjectCount returns the number of objects in the archive, e.g. the slices of a universal binary
+func (a *Archive) ObjectCount() (int, error) {
 	C.symbolic_err_clear()
 	res := int(C.symbolic_archive_object_count(a.archive))
 
@@ -64,50 +160,47 @@ func (a *Archive) objectCount() (int, error) {
 	return res, nil
 }
 
-func (a *Archive) objects() ([]Object, error) {
-	count, err := a.objectCount()
+// Objects returns the objects in the archive, reading them does not build SymCaches.
+// Objects that cannot be read are skipped and reported as ObjectErrors joined into the
+// error, which is returned together with the readable objects.
+func (a *Archive) Objects() ([]*Object, error) {
+	objects, errs, err := a.readObjects()
 	if err != nil {
 		return nil, err
 	}
 
-	s := make([]Object, count)
-
-	for i:= 0; i<count; i++ {
-		C.symbolic_err_clear()
-		cobj := C.symbolic_archive_get_object(a.archive, C.uintptr_t(i))
-
-		err := checkErr()
-		if (err != nil) {
-			return nil, err
-		}
+	return objects, joinObjectErrors(errs)
+}
 
-		obj, err := makeObject(cobj)
-		if err != nil {
-			return nil, err
-		}
-		s[i] = *obj
+// NewArchiveFromBytes creates an archive from a byte buffer. The data is copied, the
+// caller is free to reuse it once the archive is created.
+func NewArchiveFromBytes(data []byte, opts ...ArchiveOption) (*Archive, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty archive")
 	}
-	return s, nil
-}
 
+	// the archive and its objects read from the buffer for their whole lifetime,
+	// so it needs a copy Go will not collect
+	cdata := C.CBytes(data)
 
-// NewArchiveFromBytes creates an archive from a byte buffer
-func NewArchiveFromBytes(data []byte) (*Archive, error) {
 	C.symbolic_err_clear()
-	a := C.symbolic_archive_from_bytes((*C.uint8_t)(unsafe.Pointer(&data[0])), C.uintptr_t(len(data)))
+	a := C.symbolic_archive_from_bytes((*C.uint8_t)(cdata), C.uintptr_t(len(data)))
 	err := checkErr()
 
 	if err != nil {
+		C.free(cdata)
 		return nil, err
 	}
 
 	arch := &Archive{
 		archive: a,
+		data: cdata,
+	}
+	for _, opt := range opts {
+		opt(arch)
 	}
 
-	runtime.SetFinalizer(arch, func (a *Archive) {
-		C.symbolic_archive_free(a.archive)
-	})
+	runtime.SetFinalizer(arch, freeArchive)
 
 	err = arch.buildSymCaches()
 	if err != nil {
@@ -118,7 +211,7 @@ func NewArchiveFromBytes(data []byte) (*Archive, error) {
 }
 
 // NewArchiveFromPath loads an archive from a given file path
-func NewArchiveFromPath(path string) (*Archive, error) {
+func NewArchiveFromPath(path string, opts ...ArchiveOption) (*Archive, error) {
 	c_path := C.CString(path)
 	defer C.free(unsafe.Pointer(c_path))
 
@@ -133,9 +226,10 @@ func NewArchiveFromPath(path string) (*Archive, error) {
 	arch := &Archive{
 		archive: a,
 	}
-	runtime.SetFinalizer(arch, func (a *Archive) {
-		C.symbolic_archive_free(a.archive)
-	})
+	for _, opt := range opts {
+		opt(arch)
+	}
+	runtime.SetFinalizer(arch, freeArchive)
 
 	err = arch.buildSymCaches()
 	if err != nil {
@@ -145,3 +239,10 @@ func NewArchiveFromPath(path string) (*Archive, error) {
 	return arch, nil
 }
 
+
+func freeArchive(a *Archive) {
+	C.symbolic_archive_free(a.archive)
+	if a.data != nil {
+		C.free(a.data)
+	}
+}
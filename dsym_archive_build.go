@@ -0,0 +1,130 @@
+package symbolic
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// symCacheBuild is a symcache being built lazily, waiters block on done
+type symCacheBuild struct {
+	done     chan struct{}
+	symCache *SymCache
+	err      error
+}
+
+// WithLazySymCaches defers building SymCaches until Archive.SymCache is first called for
+// a debug ID, so only the slices of a universal binary that are needed get built.
+// The SymCaches map is not usable in this mode: it stays empty and lazily built
+// symcaches are never added to it, look them up with Archive.SymCache instead.
+func WithLazySymCaches() ArchiveOption {
+	return func(a *Archive) {
+		a.lazy = true
+	}
+}
+
+// WithParallelSymCaches builds the SymCaches of all objects concurrently with the given
+// number of workers when the archive is loaded, runtime.NumCPU() is used when workers is 0 or less
+func WithParallelSymCaches(workers int) ArchiveOption {
+	return func(a *Archive) {
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		a.workers = workers
+	}
+}
+
+// SymCache returns the SymCache for the debug ID, building it first when the archive was
+// loaded with WithLazySymCaches. Concurrent callers for the same debug ID wait for a single
+// build, whose error is returned and added to Errors. nil is returned when no object has
+// the debug ID.
+func (a *Archive) SymCache(debugID string) (*SymCache, error) {
+	debugID = strings.ToLower(debugID)
+
+	if symCache, ok := a.SymCaches[debugID]; ok || !a.lazy {
+		return symCache, nil
+	}
+
+	a.mu.Lock()
+	build, ok := a.builds[debugID]
+	if ok {
+		a.mu.Unlock()
+		<-build.done
+		return build.symCache, build.err
+	}
+
+	obj, ok := a.byDebugID[debugID]
+	if !ok {
+		a.mu.Unlock()
+		return nil, nil
+	}
+
+	build = &symCacheBuild{done: make(chan struct{})}
+	a.builds[debugID] = build
+	a.mu.Unlock()
+
+	build.symCache, build.err = buildSymCache(obj)
+	if build.err != nil {
+		a.mu.Lock()
+		a.Errors = append(a.Errors, newObjectError(obj, build.err))
+		a.mu.Unlock()
+	}
+	close(build.done)
+
+	return build.symCache, build.err
+}
+
+// indexObjects prepares the lazy builds of the objects by debug ID
+func (a *Archive) indexObjects(objects []*Object) {
+	a.byDebugID = make(map[string]*Object, len(objects))
+	a.builds = make(map[string]*symCacheBuild, len(objects))
+	for _, obj := range objects {
+		a.byDebugID[strings.ToLower(obj.debugId)] = obj
+	}
+}
+
+// buildSymCachesParallel builds the symcaches of all objects, failed objects are added to Errors
+func (a *Archive) buildSymCachesParallel(objects []*Object) {
+	symCaches := make([]*SymCache, len(objects))
+	errs := make([]error, len(objects))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < a.workers && w < len(objects); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				symCaches[i], errs[i] = buildSymCache(objects[i])
+			}
+		}()
+	}
+
+	for i := range objects {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, symCache := range symCaches {
+		if errs[i] != nil {
+			a.Errors = append(a.Errors, newObjectError(objects[i], errs[i]))
+			continue
+		}
+
+		a.SymCaches[symCache.debugId] = symCache
+	}
+}
+
+// newObjectSymCache builds the symcache of an object, tests replace it to make builds fail
+var newObjectSymCache = NewSymCacheFromObject
+
+// buildSymCache builds the symcache of an object on a single OS thread,
+// symbolic keeps the last error per thread
+func buildSymCache(obj *Object) (*SymCache, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	return newObjectSymCache(obj)
+}
@@ -6,8 +6,13 @@ package symbolic
 #include "include/symbolic.h"
 */
 import "C"
-import "runtime"
+import (
+	"fmt"
+	"runtime"
+)
 
+// Object is an object of an Archive, e.g. one slice of a universal binary. It keeps its
+// Archive alive, so it stays usable after the Archive is dropped.
 type Object struct {
 	object *C.SymbolicObject
 	arch string
@@ -16,6 +21,35 @@ type Object struct {
 	kind string
 	fileFormat string
 	features *ObjectFeatures
+	// index is the position of the object in its archive
+	index int
+	// archive owns the memory object reads from, it is kept alive as long as the object is
+	archive *Archive
+}
+
+// ObjectError is an object of an Archive that could not be read or whose SymCache failed to build.
+// DebugID and Arch are empty when the object itself could not be read.
+type ObjectError struct {
+	Index   int
+	DebugID string
+	Arch    string
+	Err     error
+}
+
+func newObjectError(obj *Object, err error) *ObjectError {
+	return &ObjectError{Index: obj.index, DebugID: obj.debugId, Arch: obj.arch, Err: err}
+}
+
+func (e *ObjectError) Error() string {
+	if e.DebugID == "" {
+		return fmt.Sprintf("object %d: %v", e.Index, e.Err)
+	}
+
+	return fmt.Sprintf("object %d (%s %s): %v", e.Index, e.Arch, e.DebugID, e.Err)
+}
+
+func (e *ObjectError) Unwrap() error {
+	return e.Err
 }
 
 type ObjectFeatures struct {
@@ -25,6 +59,40 @@ type ObjectFeatures struct {
 	HasSources bool
 }
 
+// Arch is the CPU architecture of the object, e.g. "arm64"
+func (o *Object) Arch() string {
+	return o.arch
+}
+
+// CodeID identifies the executable, e.g. the build ID of an ELF file
+func (o *Object) CodeID() string {
+	return o.codeId
+}
+
+// DebugID identifies the debug information, SymCaches are keyed by it
+func (o *Object) DebugID() string {
+	return o.debugId
+}
+
+// Kind is the kind of object, e.g. "exe", "lib" or "dbg"
+func (o *Object) Kind() string {
+	return o.kind
+}
+
+// FileFormat is the container format, e.g. "macho", "elf" or "wasm"
+func (o *Object) FileFormat() string {
+	return o.fileFormat
+}
+
+// Features lists the kinds of debug information the object contains
+func (o *Object) Features() ObjectFeatures {
+	if o.features == nil {
+		return ObjectFeatures{}
+	}
+
+	return *o.features
+}
+
 func symbolicObjectGetArch(object *C.SymbolicObject) (string, error) {
 	C.symbolic_err_clear()
 	str := C.symbolic_object_get_arch(object)
@@ -7,6 +7,8 @@ package symbolic
 */
 import "C"
 import (
+	"errors"
+	"io"
 	"runtime"
 	"unsafe"
 )
@@ -16,6 +18,8 @@ type SymCache struct {
 	arch string
 	debugId string
 	ipRegName string
+	// data is the C copy of the bytes a loaded symcache reads from, nil when built from an object
+	data unsafe.Pointer
 }
 
 type SourceLocation struct {
@@ -116,11 +120,99 @@ func NewSymCacheFromObject(object *Object) (*SymCache, error) {
 	C.symbolic_err_clear()
 	sc := C.symbolic_symcache_from_object(object.object)
 	err := checkErr()
+	runtime.KeepAlive(object)
 
 	if err != nil {
 		return nil, err
 	}
 
+	return newSymCache(sc)
+}
+
+// LoadSymCacheFromBytes loads a symcache previously written with SymCache.WriteTo.
+// Check IsLatestVersion to find caches written by an older version of symbolic.
+func LoadSymCacheFromBytes(data []byte) (*SymCache, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty symcache")
+	}
+
+	// the symcache reads from the buffer for its whole lifetime, so it needs a copy Go will not collect
+	cdata := C.CBytes(data)
+
+	C.symbolic_err_clear()
+	sc := C.symbolic_symcache_from_bytes((*C.uint8_t)(cdata), C.uintptr_t(len(data)))
+	err := checkErr()
+
+	if err != nil {
+		C.free(cdata)
+		return nil, err
+	}
+
+	symcache, err := newSymCache(sc)
+	if err != nil {
+		C.free(cdata)
+		return nil, err
+	}
+	symcache.data = cdata
+
+	return symcache, nil
+}
+
+// OpenSymCache loads a symcache file previously written with SymCache.WriteTo
+func OpenSymCache(path string) (*SymCache, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	C.symbolic_err_clear()
+	sc := C.symbolic_symcache_open(cPath)
+	err := checkErr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newSymCache(sc)
+}
+
+// SymCacheLatestVersion is the symcache format version written by this build of symbolic
+func SymCacheLatestVersion() uint32 {
+	return uint32(C.symbolic_symcache_latest_version())
+}
+
+// Version is the format version of the symcache
+func (s *SymCache) Version() uint32 {
+	return uint32(C.symbolic_symcache_get_version(s.symcache))
+}
+
+// IsLatestVersion reports whether the symcache uses the latest format, older caches
+// still load but should be regenerated from the object
+func (s *SymCache) IsLatestVersion() bool {
+	return s.Version() >= SymCacheLatestVersion()
+}
+
+// Arch is the architecture of the object the symcache was built from
+func (s *SymCache) Arch() string {
+	return s.arch
+}
+
+// DebugID is the debug ID of the object the symcache was built from
+func (s *SymCache) DebugID() string {
+	return s.debugId
+}
+
+// WriteTo writes the symcache in its binary format, which LoadSymCacheFromBytes and OpenSymCache read
+func (s *SymCache) WriteTo(w io.Writer) (int64, error) {
+	data := C.symbolic_symcache_get_bytes(s.symcache)
+	size := C.symbolic_symcache_get_size(s.symcache)
+
+	// the bytes belong to the symcache, keep it alive until they are written
+	defer runtime.KeepAlive(s)
+
+	n, err := w.Write(unsafe.Slice((*byte)(unsafe.Pointer(data)), int(size)))
+	return int64(n), err
+}
+
+func newSymCache(sc *C.SymbolicSymCache) (*SymCache, error) {
 	arch, err := symCacheGetArch(sc)
 	if err != nil {
 		C.symbolic_symcache_free(sc)
@@ -147,6 +239,9 @@ func NewSymCacheFromObject(object *Object) (*SymCache, error) {
 	}
 	runtime.SetFinalizer(symcache, func (s *SymCache) {
 		C.symbolic_symcache_free(s.symcache)
+		if s.data != nil {
+			C.free(s.data)
+		}
 	})
 
 	return symcache, nil
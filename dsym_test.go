@@ -1,7 +1,12 @@
 package symbolic
 
 import (
+	"bytes"
+	"errors"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,21 +23,21 @@ func TestDSymArchiveElectron(t *testing.T) {
 	assert.NoError(t, err, "Failed to load DWARF binary")
 
 	// Get basic information about the archive
-	count, err := archive.objectCount()
+	count, err := archive.ObjectCount()
 	assert.NoError(t, err)
 	assert.Equal(t, count, 1, "Expected at least one object in the archive")
 
 	// Get the first object
-	obj, err := archive.getObject(0)
+	obj, err := archive.Object(0)
 	assert.NoError(t, err, "Failed to get object")
 	assert.NotNil(t, obj, "Object is nil")
 
-	objects, err := archive.objects()
+	objects, err := archive.Objects()
 	assert.NoError(t, err)
 	for _,obj := range objects {
-		assert.Equal(t, obj.arch, "x86_64")
+		assert.Equal(t, obj.Arch(), "x86_64")
 		// Create a symcache from the object
-		symCache, err := NewSymCacheFromObject(&obj)
+		symCache, err := NewSymCacheFromObject(obj)
 		assert.NoError(t, err, "Failed to create symcache")
 
 		// Verify a known symbol
@@ -60,38 +65,37 @@ func TestDSymArchive(t *testing.T) {
 	assert.NoError(t, err, "Failed to load DWARF binary")
 
 	// Get basic information about the archive
-	count,err := archive.objectCount()
+	count,err := archive.ObjectCount()
 	assert.NoError(t, err)
 	assert.GreaterOrEqual(t, count, 1, "Expected at least one object in the archive")
 
 	// Get the first object
-	obj, err := archive.getObject(0)
+	obj, err := archive.Object(0)
 	assert.NoError(t, err, "Failed to get object")
 	assert.NotNil(t, obj, "Object is nil")
 
-	objects, err := archive.objects()
+	objects, err := archive.Objects()
 	assert.NoError(t, err)
 	for _,obj := range objects {
-		assert.NotEmpty(t, obj.arch)
-		t.Logf("Object architecture: %s", obj.arch)
-		assert.NotEmpty(t, obj.fileFormat)
-		t.Logf("Object file format: %s", obj.fileFormat)
-		assert.NotEmpty(t, obj.kind)
-		t.Logf("Object kind: %s", obj.kind)
-		assert.NotEmpty(t, obj.debugId)
-		t.Logf("Object debug ID: %s", obj.debugId)
-
-		features := obj.features
-		assert.NotNil(t, obj.features)
+		assert.NotEmpty(t, obj.Arch())
+		t.Logf("Object architecture: %s", obj.Arch())
+		assert.NotEmpty(t, obj.FileFormat())
+		t.Logf("Object file format: %s", obj.FileFormat())
+		assert.NotEmpty(t, obj.Kind())
+		t.Logf("Object kind: %s", obj.Kind())
+		assert.NotEmpty(t, obj.DebugID())
+		t.Logf("Object debug ID: %s", obj.DebugID())
+
+		features := obj.Features()
 		t.Logf("Has debug info: %v", features.HasDebug)
 		t.Logf("Has symbols: %v", features.HasSymtab)
 
 		// Create a symcache from the object
-		symCache, err := NewSymCacheFromObject(&obj)
+		symCache, err := NewSymCacheFromObject(obj)
 		assert.NoError(t, err, "Failed to create symcache")
 
-		t.Logf("SymCache arch: %s", symCache.arch)
-		t.Logf("SymCache debug ID: %s", symCache.debugId)
+		t.Logf("SymCache arch: %s", symCache.Arch())
+		t.Logf("SymCache debug ID: %s", symCache.DebugID())
 
 
 		// Try looking up a symbol at a specific address
@@ -107,4 +111,290 @@ func TestDSymArchive(t *testing.T) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestSymCacheWriteAndLoad(t *testing.T) {
+	archive, err := NewArchiveFromPath("crashcrashcrash.app.dSYM/Contents/Resources/DWARF/crashcrashcrash")
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+
+	for debugID, symCache := range archive.SymCaches {
+		assert.Equal(t, debugID, symCache.DebugID())
+		assert.True(t, symCache.IsLatestVersion())
+		assert.Equal(t, SymCacheLatestVersion(), symCache.Version())
+
+		var buf bytes.Buffer
+		n, err := symCache.WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+
+		loaded, err := LoadSymCacheFromBytes(buf.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, symCache.DebugID(), loaded.DebugID())
+		assert.Equal(t, symCache.Arch(), loaded.Arch())
+		assert.Equal(t, symCache.Version(), loaded.Version())
+
+		expected, err := symCache.Lookup(0x10000)
+		assert.NoError(t, err)
+		locations, err := loaded.Lookup(0x10000)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, locations)
+
+		path := filepath.Join(t.TempDir(), debugID+".symcache")
+		assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+		opened, err := OpenSymCache(path)
+		assert.NoError(t, err)
+		assert.Equal(t, symCache.DebugID(), opened.DebugID())
+	}
+
+	_, err = LoadSymCacheFromBytes(nil)
+	assert.Error(t, err)
+}
+
+func TestSymCacheLoadFromBytesOwnsData(t *testing.T) {
+	archive, err := NewArchiveFromPath("crashcrashcrash.app.dSYM/Contents/Resources/DWARF/crashcrashcrash")
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+
+	for _, symCache := range archive.SymCaches {
+		expected, err := symCache.Lookup(0x10000)
+		assert.NoError(t, err)
+
+		var buf bytes.Buffer
+		_, err = symCache.WriteTo(&buf)
+		assert.NoError(t, err)
+
+		data := buf.Bytes()
+		loaded, err := LoadSymCacheFromBytes(data)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		// overwrite and drop the input, the loaded symcache must not read from it
+		for i := range data {
+			data[i] = 0
+		}
+		data = nil
+		buf = bytes.Buffer{}
+		runtime.GC()
+
+		locations, err := loaded.Lookup(0x10000)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, locations)
+		assert.Equal(t, symCache.DebugID(), loaded.DebugID())
+	}
+}
+
+func TestArchiveWithoutSymCaches(t *testing.T) {
+	archive, err := NewArchiveFromPath("crashcrashcrash.app.dSYM/Contents/Resources/DWARF/crashcrashcrash", WithoutSymCaches())
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+
+	assert.Empty(t, archive.SymCaches)
+	assert.Empty(t, archive.Errors)
+
+	objects, err := archive.Objects()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, objects)
+	for _, obj := range objects {
+		assert.Equal(t, "macho", obj.FileFormat())
+		assert.Equal(t, "dbg", obj.Kind())
+		assert.NotEmpty(t, obj.DebugID())
+		assert.True(t, obj.Features().HasDebug)
+	}
+}
+
+func TestObjectOutlivesArchive(t *testing.T) {
+	archive, err := NewArchiveFromPath("crashcrashcrash.app.dSYM/Contents/Resources/DWARF/crashcrashcrash", WithoutSymCaches())
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+
+	objects, err := archive.Objects()
+	assert.NoError(t, err)
+
+	// the objects read from the archive's memory, dropping it must not free that
+	archive = nil
+	runtime.GC()
+
+	for _, obj := range objects {
+		symCache, err := NewSymCacheFromObject(obj)
+		assert.NoError(t, err)
+		assert.Equal(t, obj.DebugID(), symCache.DebugID())
+	}
+}
+
+func TestArchiveLazySymCaches(t *testing.T) {
+	archive, err := NewArchiveFromPath("crashcrashcrash.app.dSYM/Contents/Resources/DWARF/crashcrashcrash", WithLazySymCaches())
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+
+	assert.Empty(t, archive.SymCaches)
+
+	objects, err := archive.Objects()
+	assert.NoError(t, err)
+	for _, obj := range objects {
+		// concurrent lookups of the same debug ID share a single build
+		symCaches := make([]*SymCache, 8)
+		var wg sync.WaitGroup
+		for i := range symCaches {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				symCache, err := archive.SymCache(obj.DebugID())
+				assert.NoError(t, err)
+				symCaches[i] = symCache
+			}(i)
+		}
+		wg.Wait()
+
+		assert.NotNil(t, symCaches[0])
+		for _, symCache := range symCaches {
+			assert.Same(t, symCaches[0], symCache)
+		}
+	}
+
+	symCache, err := archive.SymCache("00000000-0000-0000-0000-000000000000")
+	assert.NoError(t, err)
+	assert.Nil(t, symCache)
+}
+
+func TestArchiveLazySymCachesFromBytes(t *testing.T) {
+	binaryPath := "crashcrashcrash.app.dSYM/Contents/Resources/DWARF/crashcrashcrash"
+
+	eager, err := NewArchiveFromPath(binaryPath)
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+
+	data, err := os.ReadFile(binaryPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	archive, err := NewArchiveFromBytes(data, WithLazySymCaches())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the lazy builds happen after loading, they must not read from the caller's slice
+	for i := range data {
+		data[i] = 0
+	}
+	data = nil
+	runtime.GC()
+
+	for debugID, expected := range eager.SymCaches {
+		symCache, err := archive.SymCache(debugID)
+		if !assert.NoError(t, err) || !assert.NotNil(t, symCache) {
+			continue
+		}
+
+		expectedLocations, err := expected.Lookup(0x10000)
+		assert.NoError(t, err)
+		locations, err := symCache.Lookup(0x10000)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedLocations, locations)
+	}
+
+	_, err = NewArchiveFromBytes(nil)
+	assert.Error(t, err)
+}
+
+func TestArchiveParallelSymCaches(t *testing.T) {
+	binaryPath := "crashcrashcrash.app.dSYM/Contents/Resources/DWARF/crashcrashcrash"
+
+	sequential, err := NewArchiveFromPath(binaryPath)
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+
+	parallel, err := NewArchiveFromPath(binaryPath, WithParallelSymCaches(0))
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(sequential.SymCaches), len(parallel.SymCaches))
+	for debugID := range sequential.SymCaches {
+		symCache, err := parallel.SymCache(debugID)
+		assert.NoError(t, err)
+		assert.NotNil(t, symCache)
+	}
+}
+
+func TestArchiveFailedSymCaches(t *testing.T) {
+	binaryPath := "crashcrashcrash.app.dSYM/Contents/Resources/DWARF/crashcrashcrash"
+
+	inspected, err := NewArchiveFromPath(binaryPath, WithoutSymCaches())
+	if !assert.NoError(t, err, "Failed to load DWARF binary") {
+		return
+	}
+	objects, err := inspected.Objects()
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, objects) {
+		return
+	}
+
+	// the symcache of the first object fails to build
+	corrupt := errors.New("corrupt DWARF")
+	failing := objects[0].DebugID()
+	defer func(build func(*Object) (*SymCache, error)) { newObjectSymCache = build }(newObjectSymCache)
+	newObjectSymCache = func(obj *Object) (*SymCache, error) {
+		if obj.DebugID() == failing {
+			return nil, corrupt
+		}
+		return NewSymCacheFromObject(obj)
+	}
+
+	for _, opts := range [][]ArchiveOption{nil, {WithParallelSymCaches(2)}} {
+		archive, err := NewArchiveFromPath(binaryPath, opts...)
+		if len(objects) == 1 {
+			// without a usable object loading fails
+			assert.ErrorIs(t, err, corrupt)
+			continue
+		}
+
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Len(t, archive.SymCaches, len(objects)-1)
+		if assert.Len(t, archive.Errors, 1) {
+			assert.Equal(t, failing, archive.Errors[0].DebugID)
+			assert.ErrorIs(t, archive.Errors[0], corrupt)
+		}
+	}
+
+	// lazy builds are reported when they fail
+	archive, err := NewArchiveFromPath(binaryPath, WithLazySymCaches())
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = archive.SymCache(failing)
+	assert.ErrorIs(t, err, corrupt)
+	if assert.Len(t, archive.Errors, 1) {
+		assert.Equal(t, failing, archive.Errors[0].DebugID)
+	}
+}
+
+func TestArchiveObjectErrors(t *testing.T) {
+	corrupt := errors.New("corrupt DWARF")
+	archive := &Archive{Errors: []*ObjectError{
+		{Index: 0, Err: errors.New("unknown object")},
+		{Index: 1, DebugID: "cb63147a-c9dc-308b-8ca1-ee92a5042e8e", Arch: "arm64", Err: corrupt},
+	}}
+
+	assert.Equal(t, "object 0: unknown object", archive.Errors[0].Error())
+	assert.Equal(t, "object 1 (arm64 cb63147a-c9dc-308b-8ca1-ee92a5042e8e): corrupt DWARF", archive.Errors[1].Error())
+
+	// one good object is enough
+	assert.NoError(t, archive.checkUsable(1))
+
+	err := archive.checkUsable(0)
+	assert.ErrorIs(t, err, corrupt)
+	var objErr *ObjectError
+	assert.ErrorAs(t, err, &objErr)
+
+	assert.NoError(t, (&Archive{}).checkUsable(0))
+}
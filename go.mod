@@ -2,7 +2,10 @@ module github.com/honeycombio/symbolic-go
 
 go 1.21.3
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/stretchr/testify v1.10.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
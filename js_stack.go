@@ -0,0 +1,216 @@
+package symbolic
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SourceMapCacheResolver returns the SourceMapCache for the script at the given url.
+// Returning a nil cache and a nil error leaves the frames of that script unresolved.
+type SourceMapCacheResolver func(url string) (*SourceMapCache, error)
+
+// JSStackFrame is a single line of a JavaScript Error.stack string
+type JSStackFrame struct {
+	// Raw is the line exactly as it appeared in the stack
+	Raw string
+	// Parsed is false for lines that are not recognised as frames (e.g. the error message)
+	Parsed       bool
+	FunctionName string
+	URL          string
+	// Line and Col are 1-based, as reported by the JavaScript engines
+	Line int
+	Col  int
+	// Wasm is set for WebAssembly frames ("url:wasm-function[42]:0x1a2b"). Their Line is 1
+	// and Col the byte offset + 1, which is how wasm source maps address code.
+	Wasm         bool
+	WasmFunction int
+	// WasmOffset is the module relative byte offset, look it up in the module's SymCache
+	WasmOffset uint64
+	// Token is the original location of the frame once symbolicated
+	Token *SourceMapCacheToken
+	// Err is set when resolving or looking up the frame failed
+	Err error
+}
+
+var (
+	jsLocationRegex     = regexp.MustCompile(`^(\S+?):(\d+)(?::(\d+))?$`)
+	jsWasmLocationRegex = regexp.MustCompile(`^(\S+?):wasm-function\[(\d+)\]:0x([0-9a-fA-F]+)$`)
+	// eval frames in V8 carry the location of the eval call, e.g. "eval at foo (http://x/y.js:1:2), <anonymous>:1:3"
+	jsV8EvalRegex = regexp.MustCompile(`\((\S+?):(\d+):(\d+)\)`)
+	// eval frames in SpiderMonkey look like "http://x/y.js line 2 > eval:1:3"
+	jsGeckoEvalRegex = regexp.MustCompile(`^(\S+) line (\d+)(?: > eval line \d+)* > (?:eval|Function)`)
+)
+
+// ParseJSStack splits an Error.stack string into frames. V8 ("at fn (url:line:col)"),
+// Hermes ("at fn (address at url:1:offset)"), SpiderMonkey/JavaScriptCore ("fn@url:line:col"),
+// WebAssembly and eval frames are recognised, every other line, blank ones included, is kept with
+// Parsed set to false so joining the Raw lines with "\n" gives back the stack.
+func ParseJSStack(stack string) []*JSStackFrame {
+	lines := strings.Split(strings.ReplaceAll(stack, "\r\n", "\n"), "\n")
+	frames := make([]*JSStackFrame, 0, len(lines))
+
+	for _, line := range lines {
+		frames = append(frames, parseJSStackLine(line))
+	}
+
+	return frames
+}
+
+func parseJSStackLine(line string) *JSStackFrame {
+	frame := &JSStackFrame{Raw: line}
+	trimmed := strings.TrimSpace(line)
+
+	switch {
+	case strings.HasPrefix(trimmed, "at "):
+		parseV8Frame(frame, strings.TrimSpace(trimmed[3:]))
+	case strings.Contains(trimmed, "@"):
+		i := strings.Index(trimmed, "@")
+		parseGeckoFrame(frame, trimmed[:i], trimmed[i+1:])
+	default:
+		// JavaScriptCore omits the "@" for anonymous top level code
+		frame.Parsed = parseJSLocation(frame, trimmed)
+	}
+
+	return frame
+}
+
+func parseV8Frame(frame *JSStackFrame, rest string) {
+	frame.Parsed = true
+
+	if !strings.HasSuffix(rest, ")") {
+		// "at url:line:col" without a function name
+		if !parseJSLocation(frame, rest) {
+			frame.FunctionName = rest
+		}
+		return
+	}
+
+	// find the parenthesis matching the trailing one, function names may contain spaces
+	depth := 0
+	open := -1
+	for i := len(rest) - 1; i >= 0; i-- {
+		if rest[i] == ')' {
+			depth++
+		} else if rest[i] == '(' {
+			depth--
+			if depth == 0 {
+				open = i
+				break
+			}
+		}
+	}
+
+	if open <= 0 {
+		if !parseJSLocation(frame, rest) {
+			frame.FunctionName = rest
+		}
+		return
+	}
+
+	frame.FunctionName = strings.TrimSpace(rest[:open])
+	location := rest[open+1 : len(rest)-1]
+
+	if strings.HasPrefix(location, "eval at ") {
+		if m := jsV8EvalRegex.FindStringSubmatch(location); m != nil {
+			frame.URL = m[1]
+			frame.Line, _ = strconv.Atoi(m[2])
+			frame.Col, _ = strconv.Atoi(m[3])
+		}
+		return
+	}
+
+	// Hermes reports bytecode offsets as "address at index.android.bundle:1:<offset>"
+	parseJSLocation(frame, strings.TrimPrefix(location, "address at "))
+}
+
+func parseGeckoFrame(frame *JSStackFrame, function, location string) {
+	if m := jsGeckoEvalRegex.FindStringSubmatch(location); m != nil {
+		frame.URL = m[1]
+		frame.Line, _ = strconv.Atoi(m[2])
+	} else if location != "[native code]" && !parseJSLocation(frame, location) {
+		// an "@" in an error message does not make it a frame
+		return
+	}
+
+	frame.Parsed = true
+	frame.FunctionName = function
+}
+
+// parseJSLocation fills in the url, line and column from a "url:line:col" string
+func parseJSLocation(frame *JSStackFrame, location string) bool {
+	if m := jsWasmLocationRegex.FindStringSubmatch(location); m != nil {
+		offset, err := strconv.ParseUint(m[3], 16, 64)
+		if err != nil {
+			return false
+		}
+
+		frame.URL = m[1]
+		frame.Wasm = true
+		frame.WasmFunction, _ = strconv.Atoi(m[2])
+		frame.WasmOffset = offset
+		frame.Line = 1
+		frame.Col = int(offset) + 1
+		return true
+	}
+
+	m := jsLocationRegex.FindStringSubmatch(location)
+	if m == nil {
+		return false
+	}
+
+	frame.URL = m[1]
+	frame.Line, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		frame.Col, _ = strconv.Atoi(m[3])
+	}
+
+	return true
+}
+
+// SymbolicateJSStack parses the given Error.stack string and looks up the original
+// location of every frame in the SourceMapCache returned by the resolver for the frame's url.
+// Lines that can not be parsed or resolved are returned unchanged.
+func SymbolicateJSStack(stack string, resolver SourceMapCacheResolver, contextLines uint32) []*JSStackFrame {
+	frames := ParseJSStack(stack)
+	caches := make(map[string]*SourceMapCache)
+	errs := make(map[string]error)
+
+	for _, frame := range frames {
+		if frame.URL == "" || frame.Line == 0 {
+			continue
+		}
+
+		smc, ok := caches[frame.URL]
+		if !ok {
+			if err, failed := errs[frame.URL]; failed {
+				frame.Err = err
+				continue
+			}
+
+			var err error
+			smc, err = resolver(frame.URL)
+			if err != nil {
+				errs[frame.URL] = err
+				frame.Err = err
+				continue
+			}
+			caches[frame.URL] = smc
+		}
+
+		if smc == nil {
+			continue
+		}
+
+		// some engines omit the column, OneBased looks up the start of the line then
+		token, err := smc.Lookup(OneBased(uint32(frame.Line), uint32(frame.Col)), contextLines)
+		if err != nil {
+			frame.Err = err
+			continue
+		}
+
+		frame.Token = token
+	}
+
+	return frames
+}
@@ -0,0 +1,204 @@
+package symbolic
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSStackV8(t *testing.T) {
+	stack := "TypeError: Cannot read properties of undefined (reading 'x')\n" +
+		"    at foo (http://example.com/app.js:1:63)\n" +
+		"    at new Foo [as bar] (http://example.com/app.js:1:47)\n" +
+		"    at http://example.com/app.js:1:34\n" +
+		"    at eval (eval at run (http://example.com/app.js:2:10), <anonymous>:1:1)\n" +
+		"    at Array.map (<anonymous>)\n" +
+		"    at async Promise.all (index 0)"
+
+	frames := ParseJSStack(stack)
+	assert.Len(t, frames, 7)
+
+	assert.False(t, frames[0].Parsed)
+	assert.Equal(t, "TypeError: Cannot read properties of undefined (reading 'x')", frames[0].Raw)
+
+	assert.True(t, frames[1].Parsed)
+	assert.Equal(t, "foo", frames[1].FunctionName)
+	assert.Equal(t, "http://example.com/app.js", frames[1].URL)
+	assert.Equal(t, 1, frames[1].Line)
+	assert.Equal(t, 63, frames[1].Col)
+
+	assert.Equal(t, "new Foo [as bar]", frames[2].FunctionName)
+	assert.Equal(t, 47, frames[2].Col)
+
+	assert.Equal(t, "", frames[3].FunctionName)
+	assert.Equal(t, "http://example.com/app.js", frames[3].URL)
+	assert.Equal(t, 34, frames[3].Col)
+
+	assert.Equal(t, "eval", frames[4].FunctionName)
+	assert.Equal(t, "http://example.com/app.js", frames[4].URL)
+	assert.Equal(t, 2, frames[4].Line)
+	assert.Equal(t, 10, frames[4].Col)
+
+	assert.True(t, frames[5].Parsed)
+	assert.Equal(t, "Array.map", frames[5].FunctionName)
+	assert.Equal(t, "", frames[5].URL)
+
+	assert.True(t, frames[6].Parsed)
+	assert.Equal(t, "", frames[6].URL)
+}
+
+func TestParseJSStackKeepsBlankLines(t *testing.T) {
+	stack := "Error: boom\n\n    at foo (http://example.com/app.js:1:63)\n   \n"
+
+	frames := ParseJSStack(stack)
+	assert.Len(t, frames, 5)
+
+	raw := make([]string, len(frames))
+	for i, frame := range frames {
+		raw[i] = frame.Raw
+	}
+	assert.Equal(t, stack, strings.Join(raw, "\n"))
+
+	assert.False(t, frames[1].Parsed)
+	assert.True(t, frames[2].Parsed)
+	assert.False(t, frames[3].Parsed)
+	assert.Equal(t, "   ", frames[3].Raw)
+	assert.False(t, frames[4].Parsed)
+}
+
+func TestParseJSStackHermes(t *testing.T) {
+	stack := "Error: boom\n" +
+		"    at onPress (address at index.android.bundle:1:9876)\n" +
+		"    at anonymous (address at /data/user/0/com.app/files/index.android.bundle:1:12)\n" +
+		"    at forEach (native)"
+
+	frames := ParseJSStack(stack)
+	assert.Len(t, frames, 4)
+
+	assert.Equal(t, "onPress", frames[1].FunctionName)
+	assert.Equal(t, "index.android.bundle", frames[1].URL)
+	assert.Equal(t, 1, frames[1].Line)
+	assert.Equal(t, 9876, frames[1].Col)
+
+	assert.Equal(t, "/data/user/0/com.app/files/index.android.bundle", frames[2].URL)
+	assert.Equal(t, 12, frames[2].Col)
+
+	assert.True(t, frames[3].Parsed)
+	assert.Equal(t, "forEach", frames[3].FunctionName)
+	assert.Equal(t, "", frames[3].URL)
+}
+
+func TestParseJSStackWasm(t *testing.T) {
+	stack := "RuntimeError: unreachable\n" +
+		"    at fib (wasm://wasm/00b2f6a6:wasm-function[42]:0x1a2b)\n" +
+		"    at https://example.com/app.wasm:wasm-function[3]:0x47\n" +
+		"fib@https://example.com/app.wasm:wasm-function[42]:0x1a2b"
+
+	frames := ParseJSStack(stack)
+	assert.Len(t, frames, 4)
+
+	assert.True(t, frames[1].Wasm)
+	assert.Equal(t, "fib", frames[1].FunctionName)
+	assert.Equal(t, "wasm://wasm/00b2f6a6", frames[1].URL)
+	assert.Equal(t, 42, frames[1].WasmFunction)
+	assert.Equal(t, uint64(0x1a2b), frames[1].WasmOffset)
+	assert.Equal(t, 1, frames[1].Line)
+	assert.Equal(t, 0x1a2b+1, frames[1].Col)
+
+	assert.Equal(t, "https://example.com/app.wasm", frames[2].URL)
+	assert.Equal(t, uint64(0x47), frames[2].WasmOffset)
+
+	assert.True(t, frames[3].Wasm)
+	assert.Equal(t, "fib", frames[3].FunctionName)
+	assert.Equal(t, 42, frames[3].WasmFunction)
+}
+
+func TestParseJSStackGecko(t *testing.T) {
+	stack := "foo@http://example.com/app.js:1:63\n" +
+		"Foo.prototype.bar/<@http://example.com/app.js:1:47\n" +
+		"@http://example.com/app.js:1:34\n" +
+		"run@http://example.com/app.js line 2 > eval:1:1\n" +
+		"forEach@[native code]\n" +
+		"global code@http://example.com/app.js:3:1\n" +
+		"http://example.com/app.js:4:2\n" +
+		"mail sent to user@example.com failed"
+
+	frames := ParseJSStack(stack)
+	assert.Len(t, frames, 8)
+
+	assert.Equal(t, "foo", frames[0].FunctionName)
+	assert.Equal(t, "http://example.com/app.js", frames[0].URL)
+	assert.Equal(t, 63, frames[0].Col)
+
+	assert.Equal(t, "Foo.prototype.bar/<", frames[1].FunctionName)
+
+	assert.Equal(t, "", frames[2].FunctionName)
+	assert.Equal(t, 34, frames[2].Col)
+
+	assert.Equal(t, "run", frames[3].FunctionName)
+	assert.Equal(t, "http://example.com/app.js", frames[3].URL)
+	assert.Equal(t, 2, frames[3].Line)
+	assert.Equal(t, 0, frames[3].Col)
+
+	assert.True(t, frames[4].Parsed)
+	assert.Equal(t, "", frames[4].URL)
+
+	assert.Equal(t, "global code", frames[5].FunctionName)
+	assert.Equal(t, 3, frames[5].Line)
+
+	assert.True(t, frames[6].Parsed)
+	assert.Equal(t, 4, frames[6].Line)
+	assert.Equal(t, 2, frames[6].Col)
+
+	assert.False(t, frames[7].Parsed)
+}
+
+func TestSymbolicateJSStack(t *testing.T) {
+	minfied, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/inlining/module.js")
+	assert.NoError(t, err)
+	sourceMap, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/inlining/module.js.map")
+	assert.NoError(t, err)
+
+	smc, err := NewSourceMapCache(string(minfied), string(sourceMap))
+	assert.NoError(t, err)
+
+	resolverErr := errors.New("not found")
+	resolver := func(url string) (*SourceMapCache, error) {
+		switch url {
+		case "http://example.com/module.js":
+			return smc, nil
+		case "http://example.com/missing.js":
+			return nil, resolverErr
+		}
+		return nil, nil
+	}
+
+	stack := "Error: boom\n" +
+		"    at a (http://example.com/module.js:1:63)\n" +
+		"    at b (http://example.com/module.js:1:47)\n" +
+		"    at c (http://example.com/missing.js:1:1)\n" +
+		"    at d (http://example.com/unknown.js:1:1)"
+
+	frames := SymbolicateJSStack(stack, resolver, 0)
+	assert.Len(t, frames, 5)
+
+	assert.Nil(t, frames[0].Token)
+	assert.Equal(t, "Error: boom", frames[0].Raw)
+
+	assert.NoError(t, frames[1].Err)
+	assert.Equal(t, "../src/app.js", frames[1].Token.Src)
+	assert.Equal(t, OneBased(3, 30), frames[1].Token.Position)
+	assert.Equal(t, "buttonCallback", frames[1].Token.FunctionName)
+
+	assert.Equal(t, "../src/bar.js", frames[2].Token.Src)
+	assert.Equal(t, "bar", frames[2].Token.FunctionName)
+
+	assert.Equal(t, resolverErr, frames[3].Err)
+	assert.Nil(t, frames[3].Token)
+
+	assert.NoError(t, frames[4].Err)
+	assert.Nil(t, frames[4].Token)
+}
@@ -0,0 +1,509 @@
+package symbolic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// rawSourceMap is the JSON representation of a source map, used for the parts of
+// a source map that the C ABI does not expose
+type rawSourceMap struct {
+	Version        int                   `json:"version"`
+	File           string                `json:"file,omitempty"`
+	SourceRoot     string                `json:"sourceRoot,omitempty"`
+	Sources        []*string             `json:"sources"`
+	SourcesContent []*string             `json:"sourcesContent,omitempty"`
+	Names          []string              `json:"names"`
+	Mappings       string                `json:"mappings"`
+	Sections       []rawSourceMapSection `json:"sections,omitempty"`
+	IgnoreList     []int                 `json:"ignoreList,omitempty"`
+	// x_google_ignoreList predates ignoreList in the spec and is still emitted by some bundlers
+	XGoogleIgnoreList []int `json:"x_google_ignoreList,omitempty"`
+}
+
+type rawSourceMapSection struct {
+	Offset struct {
+		Line   uint32 `json:"line"`
+		Column uint32 `json:"column"`
+	} `json:"offset"`
+	Map *rawSourceMap `json:"map"`
+}
+
+// sourceMapSummary holds the keys of a source map needed on every lookup, decoding
+// only them skips the mappings and sourcesContent
+type sourceMapSummary struct {
+	SourceRoot        string    `json:"sourceRoot"`
+	Sources           []*string `json:"sources"`
+	IgnoreList        []int     `json:"ignoreList"`
+	XGoogleIgnoreList []int     `json:"x_google_ignoreList"`
+	Sections          []struct {
+		Map *sourceMapSummary `json:"map"`
+	} `json:"sections"`
+}
+
+// ignoredSources adds the sources in the ignoreList of the map and its sections to ignored,
+// both with and without the sourceRoot
+func (sm *sourceMapSummary) ignoredSources(ignored map[string]bool) {
+	ignoreList := sm.IgnoreList
+	if ignoreList == nil {
+		ignoreList = sm.XGoogleIgnoreList
+	}
+
+	for _, i := range ignoreList {
+		if i < 0 || i >= len(sm.Sources) || sm.Sources[i] == nil {
+			continue
+		}
+
+		name := *sm.Sources[i]
+		ignored[name] = true
+		ignored[joinSourceRoot(sm.SourceRoot, name)] = true
+	}
+
+	for _, section := range sm.Sections {
+		if section.Map != nil {
+			section.Map.ignoredSources(ignored)
+		}
+	}
+}
+
+// Mapping is a single segment of a source map's mappings
+type Mapping struct {
+	Generated Position
+	// HasSource is false for segments that only mark the end of the preceding mapping
+	HasSource bool
+	Source    string
+	Original  Position
+	// Name is the original identifier, if the segment has one
+	Name string
+}
+
+func parseRawSourceMap(sourceMap string) (*rawSourceMap, error) {
+	var sm rawSourceMap
+	if err := json.Unmarshal([]byte(sourceMap), &sm); err != nil {
+		return nil, err
+	}
+
+	return &sm, nil
+}
+
+func (sm *rawSourceMap) source(i int) string {
+	if i < 0 || i >= len(sm.Sources) || sm.Sources[i] == nil {
+		return ""
+	}
+
+	return *sm.Sources[i]
+}
+
+// sourceFile is an entry of the sources field together with its sourcesContent
+type sourceFile struct {
+	name    string
+	content *string
+	ignored bool
+}
+
+// sourceFiles lists the sources of the map and all its sections in order
+func (sm *rawSourceMap) sourceFiles() []sourceFile {
+	var files []sourceFile
+
+	ignoreList := sm.IgnoreList
+	if ignoreList == nil {
+		ignoreList = sm.XGoogleIgnoreList
+	}
+
+	ignored := make(map[int]bool, len(ignoreList))
+	for _, i := range ignoreList {
+		ignored[i] = true
+	}
+
+	for i := range sm.Sources {
+		f := sourceFile{name: sm.source(i), ignored: ignored[i]}
+		if i < len(sm.SourcesContent) {
+			f.content = sm.SourcesContent[i]
+		}
+		files = append(files, f)
+	}
+
+	for _, section := range sm.Sections {
+		if section.Map != nil {
+			files = append(files, section.Map.sourceFiles()...)
+		}
+	}
+
+	return files
+}
+
+// withSourceRoot returns the source name prefixed with the sourceRoot
+func (sm *rawSourceMap) withSourceRoot(name string) string {
+	return joinSourceRoot(sm.SourceRoot, name)
+}
+
+func joinSourceRoot(sourceRoot, name string) string {
+	if sourceRoot == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(sourceRoot, "/") + "/" + name
+}
+
+// decodeMappings decodes all segments in generated order
+func (sm *rawSourceMap) decodeMappings() ([]Mapping, error) {
+	var mappings []Mapping
+
+	it := newMappingIterator(sm)
+	for it.Next() {
+		mappings = append(mappings, it.Mapping())
+	}
+
+	return mappings, it.Err()
+}
+
+// MappingIterator walks the mappings of a source map in generated order without decoding them all up front
+type MappingIterator struct {
+	sm      *rawSourceMap
+	current Mapping
+	err     error
+
+	// decoder state of a regular source map, the values are relative to the previous segment
+	pos                                  int
+	line                                 uint32
+	col, source, origLine, origCol, name int64
+
+	// index maps are walked section by section
+	section int
+	sub     *MappingIterator
+
+	// onError, when set, is called for invalid segments which are then skipped instead of stopping the iteration
+	onError func(offset int, line uint32, segment string, err *segmentError)
+}
+
+func newMappingIterator(sm *rawSourceMap) *MappingIterator {
+	return &MappingIterator{sm: sm}
+}
+
+// Mappings returns an iterator over all mappings of the source map in generated order.
+// The cache has to be created WithRetainedInputs, ErrInputsNotRetained is returned otherwise.
+func (s *SourceMapCache) Mappings() (*MappingIterator, error) {
+	sm, err := s.raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return newMappingIterator(sm), nil
+}
+
+// Next advances to the next mapping, it returns false at the end or on an error
+func (it *MappingIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if len(it.sm.Sections) > 0 {
+		return it.nextSection()
+	}
+
+	mappings := it.sm.Mappings
+
+	for it.pos < len(mappings) {
+		switch mappings[it.pos] {
+		case ';':
+			it.line++
+			it.col = 0
+			it.pos++
+			continue
+		case ',':
+			it.pos++
+			continue
+		}
+
+		end := it.pos
+		for end < len(mappings) && mappings[end] != ',' && mappings[end] != ';' {
+			end++
+		}
+
+		start := it.pos
+		segment := mappings[start:end]
+		it.pos = end
+
+		if err := it.decodeSegment(segment); err != nil {
+			if it.onError != nil {
+				it.onError(start, it.line, segment, err)
+				continue
+			}
+
+			it.err = fmt.Errorf("line %d: %w", it.line, err)
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Mapping returns the current mapping
+func (it *MappingIterator) Mapping() Mapping {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *MappingIterator) Err() error {
+	return it.err
+}
+
+// sourceRoot returns the sourceRoot of the map, or of the section, the current mapping comes from
+func (it *MappingIterator) sourceRoot() string {
+	if it.sub != nil {
+		return it.sub.sourceRoot()
+	}
+
+	return it.sm.SourceRoot
+}
+
+func (it *MappingIterator) nextSection() bool {
+	for {
+		if it.sub == nil {
+			if it.section >= len(it.sm.Sections) {
+				return false
+			}
+
+			if it.sm.Sections[it.section].Map == nil {
+				it.err = fmt.Errorf("section %d has no map", it.section)
+				return false
+			}
+
+			it.sub = newMappingIterator(it.sm.Sections[it.section].Map)
+			it.section++
+		}
+
+		if it.sub.Next() {
+			offset := it.sm.Sections[it.section-1].Offset
+
+			m := it.sub.Mapping()
+			line, col := m.Generated.ZeroBased()
+			if line == 0 {
+				col += offset.Column
+			}
+			m.Generated = ZeroBased(line+offset.Line, col)
+
+			it.current = m
+			return true
+		}
+
+		if it.sub.Err() != nil {
+			it.err = fmt.Errorf("section %d: %w", it.section-1, it.sub.Err())
+			return false
+		}
+
+		it.sub = nil
+	}
+}
+
+// segmentError describes why a segment of the mappings could not be decoded
+type segmentError struct {
+	kind SourceMapIssueKind
+	msg  string
+}
+
+func (e *segmentError) Error() string {
+	return e.msg
+}
+
+func newSegmentError(kind SourceMapIssueKind, format string, args ...any) *segmentError {
+	return &segmentError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+func (it *MappingIterator) decodeSegment(segment string) *segmentError {
+	fields, err := decodeVLQ(segment)
+	if err != nil {
+		return &segmentError{kind: InvalidVLQ, msg: err.Error()}
+	}
+
+	if len(fields) != 1 && len(fields) != 4 && len(fields) != 5 {
+		return newSegmentError(InvalidSegment, "segment %q has %d fields", segment, len(fields))
+	}
+
+	it.col += fields[0]
+	if it.col < 0 {
+		return newSegmentError(InvalidSegment, "segment %q has a negative column", segment)
+	}
+
+	m := Mapping{Generated: ZeroBased(it.line, uint32(it.col))}
+
+	if len(fields) >= 4 {
+		it.source += fields[1]
+		it.origLine += fields[2]
+		it.origCol += fields[3]
+
+		if it.source < 0 || it.source >= int64(len(it.sm.Sources)) {
+			return newSegmentError(SourceOutOfRange, "segment %q references source %d out of range", segment, it.source)
+		}
+		if it.origLine < 0 || it.origCol < 0 {
+			return newSegmentError(InvalidSegment, "segment %q has a negative original position", segment)
+		}
+
+		m.HasSource = true
+		m.Source = it.sm.source(int(it.source))
+		m.Original = ZeroBased(uint32(it.origLine), uint32(it.origCol))
+	}
+
+	if len(fields) == 5 {
+		it.name += fields[4]
+		if it.name < 0 || it.name >= int64(len(it.sm.Names)) {
+			return newSegmentError(NameOutOfRange, "segment %q references name %d out of range", segment, it.name)
+		}
+		m.Name = it.sm.Names[it.name]
+	}
+
+	it.current = m
+
+	return nil
+}
+
+const base64VLQChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+var base64VLQValues = func() [256]int8 {
+	var values [256]int8
+	for i := range values {
+		values[i] = -1
+	}
+	for i := 0; i < len(base64VLQChars); i++ {
+		values[base64VLQChars[i]] = int8(i)
+	}
+	return values
+}()
+
+// decodeVLQ decodes all base64 VLQ values of a single segment
+func decodeVLQ(segment string) ([]int64, error) {
+	var values []int64
+	var value int64
+	var shift uint
+
+	for i := 0; i < len(segment); i++ {
+		digit := base64VLQValues[segment[i]]
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base64 character %q in segment %q", segment[i], segment)
+		}
+
+		if shift > 58 {
+			return nil, fmt.Errorf("VLQ value in segment %q is too large", segment)
+		}
+		value |= int64(digit&31) << shift
+
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+
+		if value&1 != 0 {
+			value = -(value >> 1)
+		} else {
+			value >>= 1
+		}
+		values = append(values, value)
+		value = 0
+		shift = 0
+	}
+
+	if shift != 0 {
+		return nil, fmt.Errorf("segment %q ends in the middle of a VLQ value", segment)
+	}
+
+	return values, nil
+}
+
+func encodeVLQ(sb *strings.Builder, value int64) {
+	v := value << 1
+	if value < 0 {
+		v = (-value << 1) | 1
+	}
+
+	for {
+		digit := v & 31
+		v >>= 5
+		if v > 0 {
+			digit |= 32
+		}
+		sb.WriteByte(base64VLQChars[digit])
+		if v == 0 {
+			return
+		}
+	}
+}
+
+// encodeSourceMap builds a source map from mappings in generated order
+func encodeSourceMap(file string, mappings []Mapping) (string, error) {
+	sm := rawSourceMap{
+		Version: 3,
+		File:    file,
+		Sources: []*string{},
+		Names:   []string{},
+	}
+
+	sources := make(map[string]int)
+	names := make(map[string]int)
+
+	var sb strings.Builder
+	var line uint32
+	var col, source, origLine, origCol, name int64
+	first := true
+
+	for _, m := range mappings {
+		genLine, genCol := m.Generated.ZeroBased()
+
+		for ; line < genLine; line++ {
+			sb.WriteByte(';')
+			col = 0
+			first = true
+		}
+
+		if !first {
+			sb.WriteByte(',')
+		}
+		first = false
+
+		encodeVLQ(&sb, int64(genCol)-col)
+		col = int64(genCol)
+
+		if !m.HasSource {
+			continue
+		}
+
+		si, ok := sources[m.Source]
+		if !ok {
+			si = len(sm.Sources)
+			sources[m.Source] = si
+			src := m.Source
+			sm.Sources = append(sm.Sources, &src)
+		}
+
+		oLine, oCol := m.Original.ZeroBased()
+		encodeVLQ(&sb, int64(si)-source)
+		encodeVLQ(&sb, int64(oLine)-origLine)
+		encodeVLQ(&sb, int64(oCol)-origCol)
+		source, origLine, origCol = int64(si), int64(oLine), int64(oCol)
+
+		if m.Name == "" {
+			continue
+		}
+
+		ni, ok := names[m.Name]
+		if !ok {
+			ni = len(sm.Names)
+			names[m.Name] = ni
+			sm.Names = append(sm.Names, m.Name)
+		}
+
+		encodeVLQ(&sb, int64(ni)-name)
+		name = int64(ni)
+	}
+
+	sm.Mappings = sb.String()
+
+	b, err := json.Marshal(&sm)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -1,22 +1,101 @@
 package symbolic
 
 /*
+#include <stdlib.h>
 #include <string.h>
 #include "include/symbolic.h"
+
+typedef struct {
+	SymbolicSmTokenMatch *match;
+	int err_code;
+	SymbolicStr err_msg;
+	SymbolicStr err_backtrace;
+} symbolic_go_sm_lookup_result;
+
+// looks up every position in a single call from Go, positions holds line/col pairs
+static void symbolic_go_sourcemapcache_lookup_many(const SymbolicSourceMapCache *smc, const uint32_t *positions, uintptr_t len, uint32_t context_lines, symbolic_go_sm_lookup_result *results) {
+	for (uintptr_t i = 0; i < len; i++) {
+		symbolic_err_clear();
+		results[i].match = symbolic_sourcemapcache_lookup_token(smc, positions[2 * i], positions[2 * i + 1], context_lines);
+		results[i].err_code = symbolic_err_get_last_code();
+		if (results[i].err_code != 0) {
+			results[i].err_msg = symbolic_err_get_last_message();
+			results[i].err_backtrace = symbolic_err_get_backtrace();
+		}
+	}
+}
+
+static void symbolic_go_sourcemapcache_lookup_many_free(symbolic_go_sm_lookup_result *results, uintptr_t len) {
+	for (uintptr_t i = 0; i < len; i++) {
+		if (results[i].match != NULL) {
+			symbolic_sourcemapcache_token_match_free(results[i].match);
+		}
+		if (results[i].err_code != 0) {
+			symbolic_str_free(&results[i].err_msg);
+			symbolic_str_free(&results[i].err_backtrace);
+		}
+	}
+}
 */
 import "C"
 import (
+	"encoding/json"
+	"errors"
 	"runtime"
+	"strings"
+	"sync"
 	"unsafe"
 )
 
 type SourceMapCache struct {
 	ssmc *C.SymbolicSourceMapCache
+
+	hasSource bool
+	debugID   string
+	// ignored holds the sources in the ignoreList, both with and without the sourceRoot
+	ignored map[string]bool
+	// sourceRoot of the source map, only read when source paths are normalized
+	sourceRoot string
+
+	// the inputs are only kept with WithRetainedInputs, for the parts of the source map the C ABI does not expose
+	retain    bool
+	source    string
+	sourceMap string
+
+	rawOnce sync.Once
+	rawMap  *rawSourceMap
+	rawErr  error
+
+	reverseOnce  sync.Once
+	reverseIndex map[string][]GeneratedRange
+	reverseErr   error
+
+	normalize *SourcePathOptions
+
+	hermes       bool
+	hermesScopes map[string][]hermesScope
+}
+
+// ErrInputsNotRetained is returned by the features that read the source map or minified source
+// in Go (Sources, SourceContents, Mappings, GeneratedPositionsFor, UnminifyMessage and
+// SourceMapChain.Flatten) when the cache was created without WithRetainedInputs. ConvertV8Coverage
+// skips the scripts of such caches.
+var ErrInputsNotRetained = errors.New("the SourceMapCache was created without WithRetainedInputs")
+
+// WithRetainedInputs keeps the minified source and the source map in memory next to the native
+// cache. The features the C ABI does not cover read them, without this option they return
+// ErrInputsNotRetained and only the native cache is kept.
+func WithRetainedInputs() SourceMapCacheOption {
+	return func(s *SourceMapCache) {
+		s.retain = true
+	}
 }
 
-func NewSourceMapCache(source, sourceMap string) (*SourceMapCache, error) {
+func NewSourceMapCache(source, sourceMap string, opts ...SourceMapCacheOption) (*SourceMapCache, error) {
 	cs := C.CString(source)
+	defer C.free(unsafe.Pointer(cs))
 	csm := C.CString(sourceMap)
+	defer C.free(unsafe.Pointer(csm))
 
 	C.symbolic_err_clear()
 	ssmc := C.symbolic_sourcemapcache_from_bytes(cs, C.strlen(cs), csm, C.strlen(csm))
@@ -30,12 +109,65 @@ func NewSourceMapCache(source, sourceMap string) (*SourceMapCache, error) {
 		ssmc: ssmc,
 	}
 
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.init(source, sourceMap)
+
 	runtime.SetFinalizer(s, free)
 
 	return s, nil
 }
 
-func (s *SourceMapCache) Lookup(line, col, contextLines uint32) (*SourceMapCacheToken, error) {
+// init keeps what lookups need from the inputs, and the inputs themselves with WithRetainedInputs
+func (s *SourceMapCache) init(source, sourceMap string) {
+	s.hasSource = source != ""
+	s.debugID = debugIDOf(source, sourceMap)
+	s.ignored = ignoredSources(sourceMap)
+
+	if s.normalize != nil && s.normalize.SourceRoot == "" {
+		s.sourceRoot = sourceRootOf(sourceMap)
+	}
+	if s.hermes {
+		s.hermesScopes = decodeHermesScopes(sourceMap)
+	}
+
+	if s.retain {
+		s.source = source
+		s.sourceMap = sourceMap
+	}
+}
+
+// NewSourceMapCacheFromSourceMap creates a SourceMapCache from only a source map, for when the
+// minified source is not available. Original locations and context lines (from sourcesContent)
+// resolve as usual, but FunctionName stays empty since scopes are read from the minified source.
+// Features that need the minified source return ErrNoMinifiedSource, see HasMinifiedSource.
+func NewSourceMapCacheFromSourceMap(sourceMap string, opts ...SourceMapCacheOption) (*SourceMapCache, error) {
+	return NewSourceMapCache("", sourceMap, opts...)
+}
+
+// HasMinifiedSource reports whether the cache was created with the minified source.
+// Without it FunctionName and scope resolution are unavailable.
+func (s *SourceMapCache) HasMinifiedSource() bool {
+	return s.hasSource
+}
+
+// minifiedSource returns the minified source for the features that read it in Go
+func (s *SourceMapCache) minifiedSource() (string, error) {
+	if !s.hasSource {
+		return "", ErrNoMinifiedSource
+	}
+	if !s.retain {
+		return "", ErrInputsNotRetained
+	}
+
+	return s.source, nil
+}
+
+func (s *SourceMapCache) Lookup(pos Position, contextLines uint32) (*SourceMapCacheToken, error) {
+	line, col := pos.OneBased()
+
 	C.symbolic_err_clear()
 	match := C.symbolic_sourcemapcache_lookup_token(s.ssmc, C.uint32_t(line), C.uint32_t(col), C.uint32_t(contextLines))
 	err := checkErr()
@@ -44,46 +176,193 @@ func (s *SourceMapCache) Lookup(line, col, contextLines uint32) (*SourceMapCache
 		return nil, err
 	}
 
+	// no token covers the position
+	if match == nil {
+		return nil, nil
+	}
+
 	defer C.symbolic_sourcemapcache_token_match_free(match)
 
 	smct := newSourceMapCacheToken(match)
+	s.finishToken(smct)
 
 	return smct, nil
 }
 
+// Position is a line and column in a source. Source maps count from 0 while the
+// C ABI and JavaScript engines count from 1, create positions with ZeroBased or
+// OneBased so the conversion only happens here.
+type Position struct {
+	line uint32
+	col  uint32
+}
+
+// ZeroBased creates a Position from a 0-based line and column, as used by the source map spec
+func ZeroBased(line, col uint32) Position {
+	return Position{line: line, col: col}
+}
+
+// OneBased creates a Position from a 1-based line and column, as reported in JavaScript stack traces.
+// 0 is not a valid 1-based line or column, it is clamped to the first one: OneBased(0, 0) is the same
+// position as OneBased(1, 1) and the two can not be told apart afterwards. Check for 0 before calling
+// OneBased when such input has to be rejected.
+func OneBased(line, col uint32) Position {
+	if line > 0 {
+		line--
+	}
+	if col > 0 {
+		col--
+	}
+	return Position{line: line, col: col}
+}
+
+// ZeroBased returns the 0-based line and column
+func (p Position) ZeroBased() (line, col uint32) {
+	return p.line, p.col
+}
+
+// OneBased returns the 1-based line and column
+func (p Position) OneBased() (line, col uint32) {
+	return p.line + 1, p.col + 1
+}
+
+// LookupMany looks up all positions with a single call into the C ABI. The returned
+// tokens and errors line up with the given positions, a token is nil when its lookup failed.
+func (s *SourceMapCache) LookupMany(positions []Position, contextLines uint32) ([]*SourceMapCacheToken, []error) {
+	tokens := make([]*SourceMapCacheToken, len(positions))
+	errs := make([]error, len(positions))
+
+	if len(positions) == 0 {
+		return tokens, errs
+	}
+
+	cpositions := make([]C.uint32_t, 2*len(positions))
+	for i, p := range positions {
+		line, col := p.OneBased()
+		cpositions[2*i] = C.uint32_t(line)
+		cpositions[2*i+1] = C.uint32_t(col)
+	}
+
+	results := make([]C.symbolic_go_sm_lookup_result, len(positions))
+	C.symbolic_go_sourcemapcache_lookup_many(s.ssmc, &cpositions[0], C.uintptr_t(len(positions)), C.uint32_t(contextLines), &results[0])
+	defer C.symbolic_go_sourcemapcache_lookup_many_free(&results[0], C.uintptr_t(len(results)))
+
+	for i := range results {
+		r := &results[i]
+
+		if r.err_code != 0 {
+			errs[i] = &SymbolicError{
+				ErrorCode: int(r.err_code),
+				Message:   copyStr(&r.err_msg),
+				Backtrace: copyStr(&r.err_backtrace),
+			}
+			continue
+		}
+
+		if r.match != nil {
+			tokens[i] = copySourceMapCacheToken(r.match)
+			s.finishToken(tokens[i])
+		}
+	}
+
+	return tokens, errs
+}
+
+// raw returns the source map parsed in Go, it is only parsed once
+func (s *SourceMapCache) raw() (*rawSourceMap, error) {
+	if !s.retain {
+		return nil, ErrInputsNotRetained
+	}
+
+	s.rawOnce.Do(func() {
+		s.rawMap, s.rawErr = parseRawSourceMap(s.sourceMap)
+	})
+
+	return s.rawMap, s.rawErr
+}
+
+// finishToken fills in the parts of a token the C ABI does not provide
+func (s *SourceMapCache) finishToken(t *SourceMapCacheToken) {
+	if s.hermes && (t.FunctionName == "" || t.FunctionName == "<anonymous>") {
+		if name := s.hermesFunctionName(t.Src, t.Position); name != "" {
+			t.FunctionName = name
+		}
+	}
+	t.Ignored = s.isIgnored(t.Src)
+	t.Src = s.normalizeSrc(t.Src)
+}
+
+// isIgnored reports whether src is in the source map's ignoreList
+func (s *SourceMapCache) isIgnored(src string) bool {
+	return s.ignored[src]
+}
+
+// ignoredSources finds the sources in the ignoreList of a source map. It runs on every
+// new cache, so only the keys it needs are decoded and maps without an ignoreList are not
+// decoded at all. A source map the Go parser can not read has no ignoreList as far as we
+// are concerned.
+func ignoredSources(sourceMap string) map[string]bool {
+	// also matches x_google_ignoreList
+	if !strings.Contains(sourceMap, "ignoreList") {
+		return nil
+	}
+
+	var sm sourceMapSummary
+	if err := json.Unmarshal([]byte(sourceMap), &sm); err != nil {
+		return nil
+	}
+
+	ignored := make(map[string]bool)
+	sm.ignoredSources(ignored)
+
+	return ignored
+}
+
 func free(s *SourceMapCache) {
 	C.symbolic_sourcemapcache_free(s.ssmc)
 }
 
 type SourceMapCacheToken struct {
-	Line         int
-	Col          int
+	// Position is the location in the original source
+	Position     Position
 	Src          string
 	Name         string
 	FunctionName string
 	ContextLine  string
 	PreContext   []string
 	PostContext  []string
+	// Ignored is set when Src is in the source map's ignoreList (or x_google_ignoreList),
+	// which bundlers use to mark third-party code
+	Ignored bool
 }
 
 func newSourceMapCacheToken(match *C.SymbolicSmTokenMatch) *SourceMapCacheToken {
+	return makeSourceMapCacheToken(match, decodeStr)
+}
+
+// copySourceMapCacheToken is like newSourceMapCacheToken but leaves freeing the
+// strings to symbolic_sourcemapcache_token_match_free
+func copySourceMapCacheToken(match *C.SymbolicSmTokenMatch) *SourceMapCacheToken {
+	return makeSourceMapCacheToken(match, copyStr)
+}
+
+func makeSourceMapCacheToken(match *C.SymbolicSmTokenMatch, str func(*C.SymbolicStr) string) *SourceMapCacheToken {
 	pre := make([]string, match.pre_context.len)
 	for i, s := range unsafe.Slice(match.pre_context.strs, match.pre_context.len) {
-		pre[i] = decodeStr(&s)
+		pre[i] = str(&s)
 	}
 
 	post := make([]string, match.post_context.len)
 	for i, s := range unsafe.Slice(match.post_context.strs, match.post_context.len) {
-		post[i] = decodeStr(&s)
+		post[i] = str(&s)
 	}
 
 	return &SourceMapCacheToken{
-		Line:         int(match.line),
-		Col:          int(match.col),
-		Src:          decodeStr(&match.src),
-		Name:         decodeStr(&match.name),
-		FunctionName: decodeStr(&match.function_name),
-		ContextLine:  decodeStr(&match.context_line),
+		Position:     OneBased(uint32(match.line), uint32(match.col)),
+		Src:          str(&match.src),
+		Name:         str(&match.name),
+		FunctionName: str(&match.function_name),
+		ContextLine:  str(&match.context_line),
 		PreContext:   pre,
 		PostContext:  post,
 	}
@@ -11,14 +11,76 @@ import (
 
 // the c-abi uses 1-based line and col numbers
 // the rust sourcemapcache uses 0-based line and col numbers
-// when doing a lookup add 1 to the line and col as per https://github.com/getsentry/symbolic/blob/master/symbolic-cabi/src/sourcemapcache.rs#L167
-// when comparing the result line and col add 1 as per https://github.com/getsentry/symbolic/blob/master/symbolic-cabi/src/sourcemapcache.rs#L144-L145
+// Position converts between the two, see https://github.com/getsentry/symbolic/blob/master/symbolic-cabi/src/sourcemapcache.rs#L167
+// and https://github.com/getsentry/symbolic/blob/master/symbolic-cabi/src/sourcemapcache.rs#L144-L145
+
+func TestPosition(t *testing.T) {
+	line, col := OneBased(3, 30).ZeroBased()
+	assert.Equal(t, uint32(2), line)
+	assert.Equal(t, uint32(29), col)
+
+	line, col = ZeroBased(2, 29).OneBased()
+	assert.Equal(t, uint32(3), line)
+	assert.Equal(t, uint32(30), col)
+
+	// 0 is clamped to the first line and column
+	assert.Equal(t, ZeroBased(0, 0), OneBased(0, 0))
+	assert.Equal(t, OneBased(1, 1), OneBased(0, 0))
+	assert.Equal(t, OneBased(3, 1), OneBased(3, 0))
+	assert.Equal(t, OneBased(1, 1), ZeroBased(0, 0))
+}
 
 func TestMin(t *testing.T) {
 	_, err := NewSourceMapCache("{}", "{}")
 	assert.NoError(t, err)
 }
 
+func TestLookupWithoutToken(t *testing.T) {
+	// the first mapping starts at column 4, nothing covers the columns before it
+	smc, err := NewSourceMapCache("    a();", `{"version":3,"sources":["a.js"],"names":[],"mappings":"IAAA"}`)
+	assert.NoError(t, err)
+
+	token, err := smc.Lookup(ZeroBased(0, 0), 0)
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+
+	tokens, errs := smc.LookupMany([]Position{ZeroBased(0, 0)}, 0)
+	assert.Nil(t, tokens[0])
+	assert.NoError(t, errs[0])
+}
+
+func TestRetainedInputs(t *testing.T) {
+	sourceMap := `{"version":3,"sourceRoot":"webpack://app/","sources":["a.js"],"sourcesContent":["a();"],"names":[],"mappings":"AAAA","debugId":"B8A5C5A7-5C5E-4C51-8F2B-4C3B1A7E9D10"}`
+
+	smc, err := NewSourceMapCache("a();", sourceMap)
+	assert.NoError(t, err)
+	assert.Empty(t, smc.source)
+	assert.Empty(t, smc.sourceMap)
+
+	// what lookups need is kept without the inputs
+	assert.True(t, smc.HasMinifiedSource())
+	assert.Equal(t, "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10", smc.DebugID())
+
+	_, err = smc.Sources()
+	assert.ErrorIs(t, err, ErrInputsNotRetained)
+	_, err = smc.Mappings()
+	assert.ErrorIs(t, err, ErrInputsNotRetained)
+	_, _, err = smc.UnminifyMessage("a is not defined", ZeroBased(0, 0))
+	assert.ErrorIs(t, err, ErrInputsNotRetained)
+
+	chain, err := NewSourceMapChain(smc)
+	assert.NoError(t, err)
+	_, err = chain.Flatten()
+	assert.ErrorIs(t, err, ErrInputsNotRetained)
+
+	smc, err = NewSourceMapCache("a();", sourceMap, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	sources, err := smc.Sources()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.js"}, sources)
+}
+
 func TestResolvesInlineFunction(t *testing.T) {
 	minfied, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/inlining/module.js")
 	assert.NoError(t, err)
@@ -28,26 +90,23 @@ func TestResolvesInlineFunction(t *testing.T) {
 	smc, err := NewSourceMapCache(string(minfied), string(sourceMap))
 	assert.NoError(t, err)
 
-	token, err := smc.Lookup(1, 63, 0)
+	token, err := smc.Lookup(OneBased(1, 63), 0)
 	assert.NoError(t, err)
 	assert.Equal(t, "../src/app.js", token.Src)
-	assert.Equal(t, 3, token.Line)
-	assert.Equal(t, 30, token.Col)
+	assert.Equal(t, OneBased(3, 30), token.Position)
 	assert.Equal(t, "buttonCallback", token.FunctionName)
 
-	token, err = smc.Lookup(1, 47, 0)
+	token, err = smc.Lookup(OneBased(1, 47), 0)
 	assert.NoError(t, err)
 	assert.Equal(t, "../src/bar.js", token.Src)
-	assert.Equal(t, 4, token.Line)
-	assert.Equal(t, 3, token.Col)
+	assert.Equal(t, OneBased(4, 3), token.Position)
 	assert.Equal(t, "bar", token.FunctionName)
 	assert.Equal(t, "foo", token.Name)
 
-	token, err = smc.Lookup(1, 34, 0)
+	token, err = smc.Lookup(OneBased(1, 34), 0)
 	assert.NoError(t, err)
 	assert.Equal(t, "../src/foo.js", token.Src)
-	assert.Equal(t, 2, token.Line)
-	assert.Equal(t, 9, token.Col)
+	assert.Equal(t, OneBased(2, 9), token.Position)
 	assert.Equal(t, "<anonymous>", token.FunctionName)
 }
 
@@ -60,11 +119,10 @@ func TestWritesSimpleCache(t *testing.T) {
 	smc, err := NewSourceMapCache(string(minfied), string(sourceMap))
 	assert.NoError(t, err)
 
-	token, err := smc.Lookup(1, 11, 0)
+	token, err := smc.Lookup(OneBased(1, 11), 0)
 	assert.NoError(t, err)
 	assert.Equal(t, "tests/fixtures/simple/original.js", token.Src)
-	assert.Equal(t, 2, token.Line)
-	assert.Equal(t, 10, token.Col)
+	assert.Equal(t, OneBased(2, 10), token.Position)
 	assert.Equal(t, "function abcd() {}\n", token.ContextLine)
 }
 
@@ -108,7 +166,7 @@ func TestSourceMaps(t *testing.T) {
 				sourceMap, err := os.ReadFile("source-map-tests/resources/" + tc.SourceMapFile)
 				assert.NoError(t, err)
 
-				smc, err := NewSourceMapCache(string(base), string(sourceMap))
+				smc, err := NewSourceMapCache(string(base), string(sourceMap), WithRetainedInputs())
 
 				if tc.SourceMapIsValid {
 					assert.NoError(t, err)
@@ -128,28 +186,40 @@ func TestSourceMaps(t *testing.T) {
 					t.Run(fmt.Sprintf("%s%d", action.ActionType, i), func(t *testing.T) {
 						switch action.ActionType {
 						case "checkMapping":
-							token, err := smc.Lookup(uint32(action.GeneratedLine+1), uint32(action.GeneratedColumn+1), 0)
+							token, err := smc.Lookup(ZeroBased(uint32(action.GeneratedLine), uint32(action.GeneratedColumn)), 0)
 							assert.NoError(t, err)
-							assert.Equal(t, action.OriginalColumn, token.Col-1)
-							assert.Equal(t, action.OriginalLine, token.Line-1)
+							assert.Equal(t, ZeroBased(uint32(action.OriginalLine), uint32(action.OriginalColumn)), token.Position)
 							assert.Equal(t, action.OriginalSource, token.Src)
 							assert.Equal(t, action.MappedName, token.Name)
 						case "checkMappingTransitive":
-							token, err := smc.Lookup(uint32(action.GeneratedLine+1), uint32(action.GeneratedColumn+1), 0)
-							assert.NoError(t, err)
+							caches := []*SourceMapCache{smc}
 							for _, m := range action.IntermediateMaps {
 								f, err = os.ReadFile("source-map-tests/resources/" + m)
 								assert.NoError(t, err)
 								ismc, err := NewSourceMapCache(string(base), string(f))
 								assert.NoError(t, err)
-								token, err = ismc.Lookup(uint32(token.Line), uint32(token.Col), 0)
-								assert.NoError(t, err)
+								caches = append(caches, ismc)
 							}
 
-							assert.Equal(t, action.OriginalColumn, token.Col-1)
-							assert.Equal(t, action.OriginalLine, token.Line-1)
+							chain, err := NewSourceMapChain(caches...)
+							assert.NoError(t, err)
+
+							generated := ZeroBased(uint32(action.GeneratedLine), uint32(action.GeneratedColumn))
+							original := ZeroBased(uint32(action.OriginalLine), uint32(action.OriginalColumn))
+
+							token, hops, err := chain.Lookup(generated, 0)
+							assert.NoError(t, err)
+							assert.Len(t, hops, len(caches))
+							assert.Equal(t, original, token.Position)
 							assert.Equal(t, action.OriginalSource, token.Src)
 							assert.Equal(t, action.MappedName, token.Name)
+
+							flattened, err := chain.Flatten()
+							assert.NoError(t, err)
+							token, err = flattened.Lookup(generated, 0)
+							assert.NoError(t, err)
+							assert.Equal(t, original, token.Position)
+							assert.Equal(t, action.OriginalSource, token.Src)
 						}
 					})
 				}
@@ -157,3 +227,220 @@ func TestSourceMaps(t *testing.T) {
 		}
 	}
 }
+
+func TestLookupMany(t *testing.T) {
+	minfied, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/inlining/module.js")
+	assert.NoError(t, err)
+	sourceMap, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/inlining/module.js.map")
+	assert.NoError(t, err)
+
+	smc, err := NewSourceMapCache(string(minfied), string(sourceMap))
+	assert.NoError(t, err)
+
+	positions := []Position{OneBased(1, 63), OneBased(1, 47), OneBased(1, 34)}
+
+	tokens, errs := smc.LookupMany(positions, 1)
+	assert.Len(t, tokens, len(positions))
+	assert.Len(t, errs, len(positions))
+
+	for i, p := range positions {
+		assert.NoError(t, errs[i])
+
+		expected, err := smc.Lookup(p, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, tokens[i])
+	}
+
+	assert.Equal(t, "buttonCallback", tokens[0].FunctionName)
+	assert.Equal(t, "foo", tokens[1].Name)
+	assert.Equal(t, "../src/foo.js", tokens[2].Src)
+
+	tokens, errs = smc.LookupMany(nil, 0)
+	assert.Empty(t, tokens)
+	assert.Empty(t, errs)
+}
+
+func TestNewSourceMapCacheFromSourceMap(t *testing.T) {
+	sourceMap, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/inlining/module.js.map")
+	assert.NoError(t, err)
+
+	smc, err := NewSourceMapCacheFromSourceMap(string(sourceMap))
+	assert.NoError(t, err)
+	assert.False(t, smc.HasMinifiedSource())
+
+	token, err := smc.Lookup(OneBased(1, 63), 0)
+	assert.NoError(t, err)
+	if assert.NotNil(t, token) {
+		assert.Equal(t, "../src/app.js", token.Src)
+		assert.Equal(t, OneBased(3, 30), token.Position)
+		// scopes come from the minified source
+		assert.Equal(t, "", token.FunctionName)
+	}
+
+	_, _, err = smc.UnminifyMessage("a is not defined", OneBased(1, 63))
+	assert.ErrorIs(t, err, ErrNoMinifiedSource)
+}
+
+func TestNewSourceMapCacheFromSourceMapContext(t *testing.T) {
+	smc, err := NewSourceMapCacheFromSourceMap(`{"version":3,"sources":["src/app.js"],"sourcesContent":["function a() {\n  b();\n}\n"],"names":[],"mappings":"AACE"}`)
+	assert.NoError(t, err)
+
+	// context lines come from sourcesContent, the minified source is not needed
+	token, err := smc.Lookup(OneBased(1, 1), 1)
+	assert.NoError(t, err)
+	if assert.NotNil(t, token) {
+		assert.Equal(t, OneBased(2, 3), token.Position)
+		assert.Equal(t, "  b();", token.ContextLine)
+		assert.Equal(t, []string{"function a() {"}, token.PreContext)
+		assert.Equal(t, []string{"}"}, token.PostContext)
+	}
+}
+
+func TestSourceContents(t *testing.T) {
+	smc, err := NewSourceMapCache("", `{"version":3,"sourceRoot":"webpack://app/","sources":["a.js","b.js"],"sourcesContent":["const a = 1;\n",null],"names":[],"mappings":"AAAA,CCAA"}`, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	sources, err := smc.Sources()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.js", "b.js"}, sources)
+
+	content, err := smc.SourceContents("a.js")
+	assert.NoError(t, err)
+	assert.Equal(t, "const a = 1;\n", content)
+
+	content, err = smc.SourceContents("webpack://app/a.js")
+	assert.NoError(t, err)
+	assert.Equal(t, "const a = 1;\n", content)
+
+	_, err = smc.SourceContents("b.js")
+	assert.ErrorIs(t, err, ErrNoSourceContents)
+
+	_, err = smc.SourceContents("c.js")
+	assert.ErrorIs(t, err, ErrNoSourceContents)
+}
+
+func TestIgnoreList(t *testing.T) {
+	sourceMap := `{"version":3,"sources":["src/app.js","node_modules/react/index.js"],"names":[],"mappings":"AAAA,ICAA","ignoreList":[1]}`
+
+	smc, err := NewSourceMapCache("a();b();", sourceMap)
+	assert.NoError(t, err)
+
+	token, err := smc.Lookup(OneBased(1, 1), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "src/app.js", token.Src)
+	assert.False(t, token.Ignored)
+
+	token, err = smc.Lookup(OneBased(1, 5), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "node_modules/react/index.js", token.Src)
+	assert.True(t, token.Ignored)
+
+	smc, err = NewSourceMapCache("a();b();", `{"version":3,"sources":["src/app.js","vendor.js"],"names":[],"mappings":"AAAA,ICAA","x_google_ignoreList":[1]}`)
+	assert.NoError(t, err)
+	assert.False(t, smc.isIgnored("src/app.js"))
+	assert.True(t, smc.isIgnored("vendor.js"))
+
+	// maps without an ignoreList are not decoded
+	assert.Nil(t, ignoredSources(`{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA"}`))
+
+	ignored := ignoredSources(`{"version":3,"sections":[{"offset":{"line":0,"column":0},"map":{"version":3,"sourceRoot":"webpack://app/","sources":["a.js","lib.js"],"names":[],"mappings":"AAAA","ignoreList":[1]}}]}`)
+	assert.Equal(t, map[string]bool{"lib.js": true, "webpack://app/lib.js": true}, ignored)
+}
+
+func TestGeneratedPositionsFor(t *testing.T) {
+	// a.js 0:0 is generated at 0:0 and 1:0, a.js 0:4 at 0:4, a.js 2:2 at 0:8
+	sourceMap := `{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA,IAAI,IAEF;AAFF"}`
+
+	smc, err := NewSourceMapCache("", sourceMap, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	ranges, err := smc.GeneratedPositionsFor("a.js", ZeroBased(0, 0), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Equal(t, []GeneratedRange{
+		{Original: ZeroBased(0, 0), Start: ZeroBased(0, 0), End: ZeroBased(0, 4)},
+		{Original: ZeroBased(0, 0), Start: ZeroBased(1, 0)},
+	}, ranges)
+
+	ranges, err = smc.GeneratedPositionsFor("a.js", ZeroBased(0, 2), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Len(t, ranges, 2)
+	assert.Equal(t, ZeroBased(0, 0), ranges[0].Original)
+
+	ranges, err = smc.GeneratedPositionsFor("a.js", ZeroBased(0, 2), LeastUpperBound)
+	assert.NoError(t, err)
+	assert.Equal(t, []GeneratedRange{
+		{Original: ZeroBased(0, 4), Start: ZeroBased(0, 4), End: ZeroBased(0, 8)},
+	}, ranges)
+
+	ranges, err = smc.GeneratedPositionsFor("a.js", ZeroBased(2, 0), LeastUpperBound)
+	assert.NoError(t, err)
+	assert.Equal(t, []GeneratedRange{
+		{Original: ZeroBased(2, 2), Start: ZeroBased(0, 8), End: ZeroBased(1, 0)},
+	}, ranges)
+
+	// nothing on line 1 of a.js
+	ranges, err = smc.GeneratedPositionsFor("a.js", ZeroBased(1, 0), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Empty(t, ranges)
+
+	ranges, err = smc.GeneratedPositionsFor("b.js", ZeroBased(0, 0), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Empty(t, ranges)
+}
+
+func TestGeneratedPositionsForSectionSourceRoots(t *testing.T) {
+	sourceMap := `{"version":3,"sourceRoot":"top/","sections":[` +
+		`{"offset":{"line":0,"column":0},"map":{"version":3,"sourceRoot":"first/","sources":["a.js"],"names":[],"mappings":"AAAA"}},` +
+		`{"offset":{"line":1,"column":0},"map":{"version":3,"sourceRoot":"second/","sources":["a.js"],"names":[],"mappings":"AAAA"}}]}`
+
+	smc, err := NewSourceMapCache("", sourceMap, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	// each section's sources are rooted with the section's own sourceRoot
+	ranges, err := smc.GeneratedPositionsFor("first/a.js", ZeroBased(0, 0), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Equal(t, []GeneratedRange{{Original: ZeroBased(0, 0), Start: ZeroBased(0, 0), End: ZeroBased(1, 0)}}, ranges)
+
+	ranges, err = smc.GeneratedPositionsFor("second/a.js", ZeroBased(0, 0), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Equal(t, []GeneratedRange{{Original: ZeroBased(0, 0), Start: ZeroBased(1, 0)}}, ranges)
+
+	ranges, err = smc.GeneratedPositionsFor("top/a.js", ZeroBased(0, 0), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Empty(t, ranges)
+
+	// unrooted the name matches both sections
+	ranges, err = smc.GeneratedPositionsFor("a.js", ZeroBased(0, 0), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Len(t, ranges, 2)
+}
+
+func TestMappings(t *testing.T) {
+	smc, err := NewSourceMapCache("a();b();", `{"version":3,"sources":["a.js","b.js"],"names":["a","b"],"mappings":"AAAAA,CAAC,CCACC;A"}`, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	it, err := smc.Mappings()
+	assert.NoError(t, err)
+
+	var mappings []Mapping
+	for it.Next() {
+		mappings = append(mappings, it.Mapping())
+	}
+	assert.NoError(t, it.Err())
+
+	assert.Equal(t, []Mapping{
+		{Generated: ZeroBased(0, 0), HasSource: true, Source: "a.js", Original: ZeroBased(0, 0), Name: "a"},
+		{Generated: ZeroBased(0, 1), HasSource: true, Source: "a.js", Original: ZeroBased(0, 1)},
+		{Generated: ZeroBased(0, 2), HasSource: true, Source: "b.js", Original: ZeroBased(0, 2), Name: "b"},
+		{Generated: ZeroBased(1, 0)},
+	}, mappings)
+
+	smc, err = NewSourceMapCache("", `{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA,!"}`, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	it, err = smc.Mappings()
+	assert.NoError(t, err)
+	assert.True(t, it.Next())
+	assert.False(t, it.Next())
+	assert.Error(t, it.Err())
+}
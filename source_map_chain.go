@@ -0,0 +1,119 @@
+package symbolic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SourceMapChain follows a position through the source maps of several build steps,
+// e.g. Terser -> Babel -> TypeScript
+type SourceMapChain struct {
+	// caches are ordered from the final generated output to the original sources
+	caches []*SourceMapCache
+}
+
+// NewSourceMapChain creates a chain from caches ordered from the final generated
+// output to the original sources: each cache maps the sources of the one before it.
+func NewSourceMapChain(caches ...*SourceMapCache) (*SourceMapChain, error) {
+	if len(caches) == 0 {
+		return nil, errors.New("a source map chain needs at least one SourceMapCache")
+	}
+
+	for i, c := range caches {
+		if c == nil {
+			return nil, fmt.Errorf("SourceMapCache %d of the chain is nil", i)
+		}
+	}
+
+	return &SourceMapChain{caches: caches}, nil
+}
+
+// Lookup resolves a generated position to its original position. The token found in
+// every cache of the chain is returned as hops, the last hop being the original token.
+// When a cache has no token for the position, the returned token is nil and hops ends
+// with the last cache that did resolve it.
+func (c *SourceMapChain) Lookup(pos Position, contextLines uint32) (*SourceMapCacheToken, []*SourceMapCacheToken, error) {
+	hops := make([]*SourceMapCacheToken, 0, len(c.caches))
+
+	for i, cache := range c.caches {
+		token, err := cache.Lookup(pos, contextLines)
+		if err != nil {
+			return nil, hops, fmt.Errorf("source map %d of the chain: %w", i, err)
+		}
+
+		if token == nil {
+			return nil, hops, nil
+		}
+
+		hops = append(hops, token)
+		pos = token.Position
+	}
+
+	return hops[len(hops)-1], hops, nil
+}
+
+// Flatten composes the chain into a single SourceMapCache that maps the final
+// generated source directly to the original sources. Mappings that can not be
+// followed through every cache of the chain are dropped. The first cache has to be
+// created WithRetainedInputs, the flattened cache retains its inputs too.
+func (c *SourceMapChain) Flatten() (*SourceMapCache, error) {
+	first := c.caches[0]
+
+	sm, err := first.raw()
+	if err != nil {
+		return nil, err
+	}
+
+	mappings, err := sm.decodeMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve the original positions of all segments through the rest of the chain, one cache at a time
+	var indices []int
+	var positions []Position
+	for i, m := range mappings {
+		if m.HasSource {
+			indices = append(indices, i)
+			positions = append(positions, m.Original)
+		}
+	}
+
+	for hop, cache := range c.caches[1:] {
+		tokens, errs := cache.LookupMany(positions, 0)
+
+		n := 0
+		for i, token := range tokens {
+			if errs[i] != nil {
+				return nil, fmt.Errorf("source map %d of the chain: %w", hop+1, errs[i])
+			}
+
+			m := &mappings[indices[i]]
+			if token == nil {
+				m.HasSource = false
+				m.Name = ""
+				continue
+			}
+
+			m.Source = token.Src
+			m.Original = token.Position
+			if token.Name != "" {
+				m.Name = token.Name
+			}
+
+			indices[n] = indices[i]
+			positions[n] = token.Position
+			n++
+		}
+
+		indices = indices[:n]
+		positions = positions[:n]
+	}
+
+	flattened, err := encodeSourceMap(sm.File, mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSourceMapCache(first.source, flattened, WithRetainedInputs())
+}
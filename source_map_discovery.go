@@ -0,0 +1,213 @@
+package symbolic
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrNoSourceMapURL is returned when a minified source does not reference a source map
+var ErrNoSourceMapURL = errors.New("no sourceMappingURL found")
+
+// ErrSourceMapOutsideRoot is returned by FileSourceMapLoader for references that leave its root
+var ErrSourceMapOutsideRoot = errors.New("source map is outside of the loader's root")
+
+// DefaultMaxSourceMapSize is the largest source map HTTPSourceMapLoader reads when no limit is given
+const DefaultMaxSourceMapSize = 128 << 20
+
+// SourceMapLoader fetches the source map at the given url, which has already been
+// resolved against the url of the minified source
+type SourceMapLoader func(url string) ([]byte, error)
+
+// matches both the current "//#" and the legacy "//@" comment forms, as well as CSS style comments
+var sourceMappingURLRegex = regexp.MustCompile(`(?m)^[ \t]*(?://|/\*)[#@][ \t]*sourceMappingURL=([^\s*]+)[ \t]*(?:\*/)?[ \t]*$`)
+
+// DiscoverSourceMapURL returns the source map reference of a minified source.
+// The SourceMap (or legacy X-SourceMap) response header takes precedence over the
+// sourceMappingURL comment, header may be nil. An empty string is returned when there is no reference.
+func DiscoverSourceMapURL(source string, header http.Header) string {
+	if header != nil {
+		if ref := header.Get("SourceMap"); ref != "" {
+			return ref
+		}
+		if ref := header.Get("X-SourceMap"); ref != "" {
+			return ref
+		}
+	}
+
+	// the last comment wins, bundlers append theirs to the end of the file
+	matches := sourceMappingURLRegex.FindAllStringSubmatch(source, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	return matches[len(matches)-1][1]
+}
+
+// LoadSourceMap returns the contents of the source map referenced by sourceMapURL.
+// Inline data: urls are decoded directly, anything else is resolved against
+// bundleURL and fetched with the loader.
+func LoadSourceMap(bundleURL, sourceMapURL string, loader SourceMapLoader) ([]byte, error) {
+	if strings.HasPrefix(sourceMapURL, "data:") {
+		return decodeDataURL(sourceMapURL)
+	}
+
+	resolved, err := resolveSourceMapURL(bundleURL, sourceMapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if loader == nil {
+		return nil, fmt.Errorf("no loader to fetch source map %s", resolved)
+	}
+
+	return loader(resolved)
+}
+
+// NewSourceMapCacheFromMinified creates a SourceMapCache for a minified source that
+// references its source map with a sourceMappingURL comment or, when header is the
+// response header the source was served with, a SourceMap header. header may be nil.
+func NewSourceMapCacheFromMinified(bundleURL, source string, header http.Header, loader SourceMapLoader, opts ...SourceMapCacheOption) (*SourceMapCache, error) {
+	ref := DiscoverSourceMapURL(source, header)
+	if ref == "" {
+		return nil, ErrNoSourceMapURL
+	}
+
+	sourceMap, err := LoadSourceMap(bundleURL, ref, loader)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSourceMapCache(source, string(sourceMap), opts...)
+}
+
+func resolveSourceMapURL(bundleURL, sourceMapURL string) (string, error) {
+	ref, err := url.Parse(sourceMapURL)
+	if err != nil {
+		return "", err
+	}
+
+	if bundleURL == "" || ref.IsAbs() {
+		return ref.String(), nil
+	}
+
+	base, err := url.Parse(bundleURL)
+	if err != nil {
+		return "", err
+	}
+
+	resolved := base.ResolveReference(ref)
+
+	// url resolution always returns an absolute path, keep the result of a relative bundle
+	// url relative so FileSourceMapLoader can tell it from a reference to an absolute path
+	if !base.IsAbs() && base.Host == "" && !strings.HasPrefix(base.Path, "/") && !strings.HasPrefix(ref.Path, "/") {
+		resolved.Path = strings.TrimPrefix(resolved.Path, "/")
+		resolved.RawPath = strings.TrimPrefix(resolved.RawPath, "/")
+	}
+
+	return resolved.String(), nil
+}
+
+func decodeDataURL(dataURL string) ([]byte, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !ok {
+		return nil, fmt.Errorf("malformed data url")
+	}
+
+	if strings.HasSuffix(meta, ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			// some bundlers strip the padding
+			decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+		}
+		return decoded, err
+	}
+
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(decoded), nil
+}
+
+// FileSourceMapLoader loads source maps from relative paths inside root, the current
+// directory when root is empty. Source map references come from untrusted bundles, so
+// file: urls, absolute paths and paths that leave root are rejected with ErrSourceMapOutsideRoot.
+func FileSourceMapLoader(root string) SourceMapLoader {
+	if root == "" {
+		root = "."
+	}
+
+	return func(ref string) ([]byte, error) {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme == "file" {
+			return nil, fmt.Errorf("%w: %s", ErrSourceMapOutsideRoot, ref)
+		}
+		if u.Scheme != "" {
+			return nil, fmt.Errorf("unsupported source map url %s", ref)
+		}
+
+		// url.Parse already decoded escapes like %2F, so u.Path is what is read from disk
+		if strings.HasPrefix(u.Path, "/") || filepath.IsAbs(filepath.FromSlash(u.Path)) {
+			return nil, fmt.Errorf("%w: %s", ErrSourceMapOutsideRoot, ref)
+		}
+
+		path := filepath.Join(root, filepath.FromSlash(u.Path))
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%w: %s", ErrSourceMapOutsideRoot, ref)
+		}
+
+		return os.ReadFile(path)
+	}
+}
+
+// HTTPSourceMapLoader fetches source maps with the given client, http.DefaultClient is used when client is nil.
+// Source maps larger than maxSize bytes are rejected, DefaultMaxSourceMapSize is used when maxSize is 0 or less.
+func HTTPSourceMapLoader(client *http.Client, maxSize int64) SourceMapLoader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSourceMapSize
+	}
+
+	return func(ref string) ([]byte, error) {
+		resp, err := client.Get(ref)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching source map %s: %s", ref, resp.Status)
+		}
+
+		if resp.ContentLength > maxSize {
+			return nil, fmt.Errorf("source map %s is larger than %d bytes", ref, maxSize)
+		}
+
+		// read one byte more than allowed to tell a source map of exactly maxSize from a larger one
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > maxSize {
+			return nil, fmt.Errorf("source map %s is larger than %d bytes", ref, maxSize)
+		}
+
+		return data, nil
+	}
+}
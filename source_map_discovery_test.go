@@ -0,0 +1,160 @@
+package symbolic
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoverSourceMapURL(t *testing.T) {
+	assert.Equal(t, "app.js.map", DiscoverSourceMapURL("var a=1;\n//# sourceMappingURL=app.js.map\n", nil))
+	assert.Equal(t, "legacy.js.map", DiscoverSourceMapURL("var a=1;\n//@ sourceMappingURL=legacy.js.map", nil))
+	assert.Equal(t, "app.css.map", DiscoverSourceMapURL("a{}\n/*# sourceMappingURL=app.css.map */", nil))
+	assert.Equal(t, "second.map", DiscoverSourceMapURL("//# sourceMappingURL=first.map\nvar a=1;\n//# sourceMappingURL=second.map", nil))
+	assert.Equal(t, "", DiscoverSourceMapURL("var s = '//# sourceMappingURL=nope.map';", nil))
+	assert.Equal(t, "", DiscoverSourceMapURL("var a=1;", nil))
+
+	header := http.Header{}
+	header.Set("SourceMap", "/maps/app.js.map")
+	assert.Equal(t, "/maps/app.js.map", DiscoverSourceMapURL("//# sourceMappingURL=app.js.map", header))
+
+	header = http.Header{}
+	header.Set("X-SourceMap", "/maps/old.js.map")
+	assert.Equal(t, "/maps/old.js.map", DiscoverSourceMapURL("", header))
+}
+
+func TestLoadSourceMapDataURL(t *testing.T) {
+	sourceMap := `{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA"}`
+
+	encoded := "data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(sourceMap))
+	data, err := LoadSourceMap("http://example.com/app.js", encoded, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, sourceMap, string(data))
+
+	data, err = LoadSourceMap("", "data:application/json,%7B%22version%22%3A3%7D", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":3}`, string(data))
+
+	_, err = LoadSourceMap("", "data:application/json;base64", nil)
+	assert.Error(t, err)
+}
+
+func TestLoadSourceMapHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/static/maps/app.js.map" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"version":3}`))
+	}))
+	defer server.Close()
+
+	loader := HTTPSourceMapLoader(server.Client(), 0)
+
+	data, err := LoadSourceMap(server.URL+"/static/js/app.js", "../maps/app.js.map", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":3}`, string(data))
+
+	_, err = LoadSourceMap(server.URL+"/static/js/app.js", "missing.js.map", loader)
+	assert.Error(t, err)
+
+	// larger than the limit
+	_, err = LoadSourceMap(server.URL+"/static/js/app.js", "../maps/app.js.map", HTTPSourceMapLoader(server.Client(), 5))
+	assert.Error(t, err)
+
+	data, err = LoadSourceMap(server.URL+"/static/js/app.js", "../maps/app.js.map", HTTPSourceMapLoader(server.Client(), int64(len(`{"version":3}`))))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":3}`, string(data))
+}
+
+func TestLoadSourceMapFile(t *testing.T) {
+	loader := FileSourceMapLoader("symbolic/symbolic-testutils/fixtures/sourcemapcache")
+
+	data, err := LoadSourceMap("simple/minified.js", "minified.js.map", loader)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, data)
+
+	_, err = LoadSourceMap("simple/minified.js", "ftp://example.com/minified.js.map", loader)
+	assert.Error(t, err)
+}
+
+func TestFileSourceMapLoaderStaysInRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "maps")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "static"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "static", "app.js.map"), []byte(`{"version":3}`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o644))
+
+	loader := FileSourceMapLoader(root)
+
+	data, err := LoadSourceMap("static/app.js", "app.js.map", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":3}`, string(data))
+
+	// url resolution drops ".." segments above the bundle url, the escaped ones survive it
+	_, err = LoadSourceMap("static/app.js", "../../secret", loader)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = loader("../secret")
+	assert.ErrorIs(t, err, ErrSourceMapOutsideRoot)
+
+	for _, ref := range []string{
+		"..%2F..%2Fsecret",
+		"..%2F..%2F..%2F..%2F..%2F..%2Fetc%2Fpasswd",
+		"/etc/passwd",
+		"file:///etc/passwd",
+		"file://" + filepath.ToSlash(filepath.Join(dir, "secret")),
+	} {
+		_, err := LoadSourceMap("static/app.js", ref, loader)
+		assert.ErrorIs(t, err, ErrSourceMapOutsideRoot, ref)
+	}
+
+	// without a root the current directory is the root
+	_, err = LoadSourceMap("", "file:///etc/passwd", FileSourceMapLoader(""))
+	assert.ErrorIs(t, err, ErrSourceMapOutsideRoot)
+	_, err = LoadSourceMap("", "../../../../../../etc/passwd", FileSourceMapLoader(""))
+	assert.ErrorIs(t, err, ErrSourceMapOutsideRoot)
+}
+
+func TestNewSourceMapCacheFromMinified(t *testing.T) {
+	minfied, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/simple/minified.js")
+	assert.NoError(t, err)
+	sourceMap, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/simple/minified.js.map")
+	assert.NoError(t, err)
+
+	source := string(minfied) + "\n//# sourceMappingURL=data:application/json;base64," + base64.StdEncoding.EncodeToString(sourceMap)
+
+	smc, err := NewSourceMapCacheFromMinified("http://example.com/minified.js", source, nil, nil)
+	assert.NoError(t, err)
+
+	token, err := smc.Lookup(OneBased(1, 11), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "tests/fixtures/simple/original.js", token.Src)
+	assert.Equal(t, OneBased(2, 10), token.Position)
+
+	_, err = NewSourceMapCacheFromMinified("http://example.com/minified.js", "var a=1;", nil, nil)
+	assert.ErrorIs(t, err, ErrNoSourceMapURL)
+}
+
+func TestNewSourceMapCacheFromMinifiedHeader(t *testing.T) {
+	var loaded string
+	loader := func(url string) ([]byte, error) {
+		loaded = url
+		return nil, os.ErrNotExist
+	}
+
+	header := http.Header{}
+	header.Set("SourceMap", "/maps/app.js.map")
+
+	_, err := NewSourceMapCacheFromMinified("http://example.com/js/app.js", "//# sourceMappingURL=app.js.map", header, loader)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, "http://example.com/maps/app.js.map", loaded)
+
+	// the header alone is enough
+	_, err = NewSourceMapCacheFromMinified("http://example.com/js/app.js", "var a=1;", header, loader)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,145 @@
+package symbolic
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// WithHermesSourceMap treats the source map as one composed for Hermes by React Native.
+// Frames of Hermes stack traces ("address at index.android.bundle:1:<offset>") are on
+// line 1 with the bytecode offset as column, which the composed map resolves like any other
+// position. When a token has no function name, or only "<anonymous>", the name is taken
+// from the x_facebook_sources function maps Metro writes into the map. The
+// x_hermes_function_offsets of the map are not used.
+func WithHermesSourceMap() SourceMapCacheOption {
+	return func(s *SourceMapCache) {
+		s.hermes = true
+	}
+}
+
+// facebookFunctionMap is the first entry of a source's x_facebook_sources metadata
+type facebookFunctionMap struct {
+	Names    []string `json:"names"`
+	Mappings string   `json:"mappings"`
+}
+
+// hermesScope is the start of a function in the original source, line is 1-based and col 0-based like in Metro
+type hermesScope struct {
+	line int64
+	col  int64
+	name string
+}
+
+// hermesFunctionName returns the name of the function enclosing pos in src
+func (s *SourceMapCache) hermesFunctionName(src string, pos Position) string {
+	scopes := s.hermesScopes[src]
+	line, _ := pos.OneBased()
+	_, col := pos.ZeroBased()
+
+	// the last scope starting at or before pos
+	i := sort.Search(len(scopes), func(i int) bool {
+		return scopes[i].line > int64(line) || (scopes[i].line == int64(line) && scopes[i].col > int64(col))
+	})
+	if i == 0 {
+		return ""
+	}
+
+	return scopes[i-1].name
+}
+
+// hermesSourceMap holds the keys of a source map needed for Hermes function names
+type hermesSourceMap struct {
+	SourceRoot       string            `json:"sourceRoot"`
+	Sources          []*string         `json:"sources"`
+	XFacebookSources []json.RawMessage `json:"x_facebook_sources"`
+}
+
+// decodeHermesScopes decodes the function maps of all sources when the cache is created,
+// keyed by source both with and without the sourceRoot
+func decodeHermesScopes(sourceMap string) map[string][]hermesScope {
+	hermesScopes := make(map[string][]hermesScope)
+
+	var sm hermesSourceMap
+	if err := json.Unmarshal([]byte(sourceMap), &sm); err != nil {
+		return hermesScopes
+	}
+
+	for i, metadata := range sm.XFacebookSources {
+		if i >= len(sm.Sources) {
+			break
+		}
+		if sm.Sources[i] == nil {
+			continue
+		}
+
+		var entries []json.RawMessage
+		if err := json.Unmarshal(metadata, &entries); err != nil || len(entries) == 0 {
+			continue
+		}
+
+		var fm facebookFunctionMap
+		if err := json.Unmarshal(entries[0], &fm); err != nil {
+			continue
+		}
+
+		scopes, err := decodeFacebookFunctionMap(&fm)
+		if err != nil {
+			continue
+		}
+
+		name := *sm.Sources[i]
+		hermesScopes[name] = scopes
+		hermesScopes[joinSourceRoot(sm.SourceRoot, name)] = scopes
+	}
+
+	return hermesScopes
+}
+
+// decodeFacebookFunctionMap decodes the [column, name index, line] segments of a
+// function map, all relative to the previous segment except for the column, which
+// starts over on every ";" separated group. Metro starts a new group whenever the
+// line advances, only those segments carry the line delta.
+func decodeFacebookFunctionMap(fm *facebookFunctionMap) ([]hermesScope, error) {
+	var scopes []hermesScope
+	line, name := int64(1), int64(0)
+
+	for _, group := range strings.Split(fm.Mappings, ";") {
+		col := int64(0)
+
+		for _, segment := range strings.Split(group, ",") {
+			if segment == "" {
+				continue
+			}
+
+			values, err := decodeVLQ(segment)
+			if err != nil {
+				return nil, err
+			}
+			if len(values) < 2 {
+				continue
+			}
+
+			col += values[0]
+			name += values[1]
+			if len(values) > 2 {
+				line += values[2]
+			}
+
+			scope := hermesScope{line: line, col: col}
+			if name >= 0 && name < int64(len(fm.Names)) {
+				scope.name = fm.Names[name]
+			}
+			scopes = append(scopes, scope)
+		}
+	}
+
+	sort.SliceStable(scopes, func(i, j int) bool {
+		if scopes[i].line != scopes[j].line {
+			return scopes[i].line < scopes[j].line
+		}
+		return scopes[i].col < scopes[j].col
+	})
+
+	return scopes, nil
+}
@@ -0,0 +1,92 @@
+package symbolic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// onPress starts at line 3, column 4 of App.js, everything before it is <global>
+const testHermesSourceMap = `{
+	"version": 3,
+	"sources": ["App.js"],
+	"names": [],
+	"mappings": "AAAA;AAEI",
+	"x_facebook_sources": [[{"names": ["<global>", "onPress"], "mappings": "AAA,ICE"}]]
+}`
+
+func TestHermesFunctionName(t *testing.T) {
+	smc, err := NewSourceMapCache("", testHermesSourceMap, WithHermesSourceMap())
+	assert.NoError(t, err)
+
+	assert.Equal(t, "<global>", smc.hermesFunctionName("App.js", OneBased(2, 1)))
+	assert.Equal(t, "onPress", smc.hermesFunctionName("App.js", OneBased(3, 5)))
+	assert.Equal(t, "onPress", smc.hermesFunctionName("App.js", OneBased(7, 1)))
+	assert.Equal(t, "", smc.hermesFunctionName("Other.js", OneBased(3, 5)))
+}
+
+func TestHermesLookup(t *testing.T) {
+	smc, err := NewSourceMapCache("", testHermesSourceMap, WithHermesSourceMap())
+	assert.NoError(t, err)
+
+	// the bytecode offset is the column of line 1
+	token, err := smc.Lookup(OneBased(1, 1), 0)
+	assert.NoError(t, err)
+	if assert.NotNil(t, token) {
+		assert.Equal(t, "App.js", token.Src)
+		assert.Equal(t, "<global>", token.FunctionName)
+	}
+}
+
+// the function map Metro generates for
+//
+//	import React from 'react';
+//
+//	export default function App() {
+//	  const onPress = () => {
+//	    log();
+//	  };
+//	  return null;
+//	}
+var testMetroFunctionMap = facebookFunctionMap{
+	Names:    []string{"<global>", "App", "onPress"},
+	Mappings: "AA;eCE;kBCC;GDE;CDE",
+}
+
+func TestDecodeFacebookFunctionMapMetro(t *testing.T) {
+	scopes, err := decodeFacebookFunctionMap(&testMetroFunctionMap)
+	assert.NoError(t, err)
+	assert.Equal(t, []hermesScope{
+		{line: 1, col: 0, name: "<global>"},
+		{line: 3, col: 15, name: "App"},
+		{line: 4, col: 18, name: "onPress"},
+		{line: 6, col: 3, name: "App"},
+		{line: 8, col: 1, name: "<global>"},
+	}, scopes)
+
+	sourceMap, err := json.Marshal(map[string]any{
+		"version":            3,
+		"sources":            []string{"App.js"},
+		"names":              []string{},
+		"mappings":           "AAAA",
+		"x_facebook_sources": [][]facebookFunctionMap{{testMetroFunctionMap}},
+	})
+	assert.NoError(t, err)
+
+	smc, err := NewSourceMapCache("", string(sourceMap), WithHermesSourceMap())
+	assert.NoError(t, err)
+	assert.Equal(t, "onPress", smc.hermesFunctionName("App.js", OneBased(5, 5)))
+	assert.Equal(t, "App", smc.hermesFunctionName("App.js", OneBased(7, 3)))
+	assert.Equal(t, "<global>", smc.hermesFunctionName("App.js", OneBased(8, 2)))
+}
+
+func TestDecodeFacebookFunctionMap(t *testing.T) {
+	scopes, err := decodeFacebookFunctionMap(&facebookFunctionMap{Names: []string{"<global>", "a", "b"}, Mappings: "AAA,ICE;KCC"})
+	assert.NoError(t, err)
+	assert.Equal(t, []hermesScope{
+		{line: 1, col: 0, name: "<global>"},
+		{line: 3, col: 4, name: "a"},
+		{line: 4, col: 5, name: "b"},
+	}, scopes)
+}
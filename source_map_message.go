@@ -0,0 +1,235 @@
+package symbolic
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrNoMinifiedSource is returned by features that need the minified source when the SourceMapCache was created without it
+var ErrNoMinifiedSource = errors.New("the minified source is not available")
+
+// IdentifierSubstitution is a minified identifier of an error message that was replaced by its original name
+type IdentifierSubstitution struct {
+	Minified string
+	Original string
+	// Generated is the position of the minified identifier whose name was used
+	Generated Position
+}
+
+// the expressions V8, SpiderMonkey and JavaScriptCore name in their error messages
+var errorMessageExpressionRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`(?:^|: )([\w$.]+) is not (?:a function|a constructor|defined|iterable)`),
+	regexp.MustCompile(`(?:^|: )([\w$.]+) is (?:undefined|null|not an object)`),
+	regexp.MustCompile(`(?:^|: )([\w$.]+) has no properties`),
+	regexp.MustCompile(`\((?:reading|setting) '([\w$]+)'\)`),
+	regexp.MustCompile(`Cannot (?:read|set) property '([\w$]+)' of`),
+	regexp.MustCompile(`can't access property "([\w$]+)"`),
+	regexp.MustCompile(`\(evaluating '([^']+)'\)`),
+}
+
+var identifierRegex = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+
+// maxNameLookups bounds the occurrences of an identifier looked up on the throwing line,
+// the closest ones to the throwing position are tried first
+const maxNameLookups = 32
+
+// UnminifyMessage rewrites the minified identifiers an error message names to their original
+// names. pos is the generated position of the throwing frame, the occurrences of an identifier
+// on its line are looked up starting with the closest to pos, and the first that starts a named
+// mapping is used. The substitutions made are returned in the order they appear in the message.
+// The cache has to be created WithRetainedInputs, ErrInputsNotRetained is returned otherwise.
+func (s *SourceMapCache) UnminifyMessage(message string, pos Position) (string, []IdentifierSubstitution, error) {
+	source, err := s.minifiedSource()
+	if err != nil {
+		return message, nil, err
+	}
+
+	line, col := pos.ZeroBased()
+	names := &lineNames{
+		smc:  s,
+		line: line,
+		col:  col,
+		// source map columns count UTF-16 code units
+		text:  utf16.Encode([]rune(sourceLine(source, int(line)))),
+		found: make(map[string]*lineName),
+	}
+
+	// collect the spans of the expressions first, several patterns can match the same message
+	var spans [][2]int
+	for _, re := range errorMessageExpressionRegexes {
+		for _, m := range re.FindAllStringSubmatchIndex(message, -1) {
+			spans = append(spans, [2]int{m[2], m[3]})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i][0] < spans[j][0]
+	})
+
+	replaced := make(map[int]bool)
+	var substitutions []IdentifierSubstitution
+	var b strings.Builder
+	last := 0
+
+	for _, sp := range spans {
+		for _, m := range identifierRegex.FindAllStringIndex(message[sp[0]:sp[1]], -1) {
+			start, end := sp[0]+m[0], sp[0]+m[1]
+			if replaced[start] || start < last {
+				continue
+			}
+			replaced[start] = true
+
+			minified := message[start:end]
+			candidate, err := names.lookup(minified)
+			if err != nil {
+				return message, nil, err
+			}
+			if candidate == nil || candidate.name == minified {
+				continue
+			}
+
+			b.WriteString(message[last:start])
+			b.WriteString(candidate.name)
+			last = end
+
+			substitutions = append(substitutions, IdentifierSubstitution{
+				Minified:  minified,
+				Original:  candidate.name,
+				Generated: candidate.pos,
+			})
+		}
+	}
+	b.WriteString(message[last:])
+
+	return b.String(), substitutions, nil
+}
+
+// sourceLine returns the 0-based line of source without splitting all of it
+func sourceLine(source string, line int) string {
+	for ; line > 0; line-- {
+		i := strings.IndexByte(source, '\n')
+		if i < 0 {
+			return ""
+		}
+		source = source[i+1:]
+	}
+
+	if i := strings.IndexByte(source, '\n'); i >= 0 {
+		source = source[:i]
+	}
+
+	return strings.TrimSuffix(source, "\r")
+}
+
+type lineName struct {
+	name string
+	pos  Position
+}
+
+// lineNames finds the original names of the minified identifiers on the throwing line
+type lineNames struct {
+	smc  *SourceMapCache
+	line uint32
+	col  uint32
+	text []uint16
+	// found holds the result of every identifier looked up so far, nil when it has no name
+	found map[string]*lineName
+}
+
+// lookup returns the name of the mapping starting at the occurrence of minified closest to the
+// throwing column, or nil. Lookups return the mapping covering a column, so a token only starts
+// at the occurrence when the column before it resolves to a different token.
+func (n *lineNames) lookup(minified string) (*lineName, error) {
+	if name, ok := n.found[minified]; ok {
+		return name, nil
+	}
+
+	cols := identifierColumns(n.text, minified)
+	sort.SliceStable(cols, func(i, j int) bool {
+		return distance(cols[i], n.col) < distance(cols[j], n.col)
+	})
+	if len(cols) > maxNameLookups {
+		cols = cols[:maxNameLookups]
+	}
+
+	// every occurrence is looked up together with the column before it, in one batch
+	positions := make([]Position, 0, 2*len(cols))
+	for _, col := range cols {
+		before := col
+		if col > 0 {
+			before = col - 1
+		}
+		positions = append(positions, ZeroBased(n.line, col), ZeroBased(n.line, before))
+	}
+
+	tokens, errs := n.smc.LookupMany(positions, 0)
+
+	var found *lineName
+	for i, col := range cols {
+		if err := errs[2*i]; err != nil {
+			return nil, err
+		}
+
+		token, before := tokens[2*i], tokens[2*i+1]
+		if token == nil || token.Name == "" {
+			continue
+		}
+		if col > 0 && before != nil && before.Src == token.Src && before.Position == token.Position && before.Name == token.Name {
+			continue
+		}
+
+		found = &lineName{name: token.Name, pos: ZeroBased(n.line, col)}
+		break
+	}
+
+	n.found[minified] = found
+	return found, nil
+}
+
+func distance(a, b uint32) uint32 {
+	if a < b {
+		return b - a
+	}
+	return a - b
+}
+
+// identifierColumns returns the columns at which the identifier occurs as a whole in text
+func identifierColumns(text []uint16, identifier string) []uint32 {
+	want := utf16.Encode([]rune(identifier))
+
+	var cols []uint32
+	for i := 0; i < len(text); {
+		if !isIdentifierUnit(text[i], true) || (i > 0 && isIdentifierUnit(text[i-1], false)) {
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(text) && isIdentifierUnit(text[end], false) {
+			end++
+		}
+
+		if end-i == len(want) && slices.Equal(text[i:end], want) {
+			cols = append(cols, uint32(i))
+		}
+		i = end
+	}
+
+	return cols
+}
+
+func isIdentifierUnit(c uint16, first bool) bool {
+	switch {
+	case c == '_' || c == '$':
+		return true
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package symbolic
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnminifyMessage(t *testing.T) {
+	// a -> api at column 0, b -> fetchUser at column 2
+	sourceMap := `{"version":3,"sources":["src/app.js"],"names":["api","fetchUser"],"mappings":"AAAAA,EAAIC"}`
+	smc, err := NewSourceMapCache("a.b();c();", sourceMap, WithRetainedInputs())
+	assert.NoError(t, err)
+
+	message, substitutions, err := smc.UnminifyMessage("TypeError: a.b is not a function", ZeroBased(0, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, "TypeError: api.fetchUser is not a function", message)
+	assert.Equal(t, []IdentifierSubstitution{
+		{Minified: "a", Original: "api", Generated: ZeroBased(0, 0)},
+		{Minified: "b", Original: "fetchUser", Generated: ZeroBased(0, 2)},
+	}, substitutions)
+
+	message, substitutions, err = smc.UnminifyMessage("TypeError: Cannot read properties of undefined (reading 'b')", ZeroBased(0, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, "TypeError: Cannot read properties of undefined (reading 'fetchUser')", message)
+	assert.Len(t, substitutions, 1)
+
+	// identifiers without a name are left alone
+	message, substitutions, err = smc.UnminifyMessage("ReferenceError: c is not defined", ZeroBased(0, 6))
+	assert.NoError(t, err)
+	assert.Equal(t, "ReferenceError: c is not defined", message)
+	assert.Empty(t, substitutions)
+}
+
+func TestUnminifyMessageWithoutSource(t *testing.T) {
+	smc, err := NewSourceMapCacheFromSourceMap(`{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA"}`)
+	assert.NoError(t, err)
+
+	message, _, err := smc.UnminifyMessage("a is not defined", ZeroBased(0, 0))
+	assert.ErrorIs(t, err, ErrNoMinifiedSource)
+	assert.Equal(t, "a is not defined", message)
+}
+
+func TestUnminifyMessageLine(t *testing.T) {
+	assert.Equal(t, "b();", sourceLine("a();\r\nb();\nc();", 1))
+	assert.Equal(t, "c();", sourceLine("a();\nb();\nc();", 2))
+	assert.Equal(t, "", sourceLine("a();", 3))
+
+	// only whole identifiers count, columns are UTF-16 code units
+	text := utf16.Encode([]rune("a.ab(a,😀a,$a,a1)+a"))
+	assert.Equal(t, []uint32{0, 5, 9, 18}, identifierColumns(text, "a"))
+	assert.Equal(t, []uint32{2}, identifierColumns(text, "ab"))
+}
@@ -0,0 +1,287 @@
+package symbolic
+
+import (
+	"container/list"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var debugIDCommentRegex = regexp.MustCompile(`(?m)^[ \t]*//[#@][ \t]*debugId=([0-9a-fA-F-]+)[ \t]*$`)
+
+// normalizeDebugID returns the debug ID in its lowercase, hyphenated form or an empty string if it is not a UUID
+func normalizeDebugID(id string) string {
+	raw := strings.ReplaceAll(strings.TrimSpace(id), "-", "")
+	if len(raw) != 32 {
+		return ""
+	}
+
+	if _, err := hex.DecodeString(raw); err != nil {
+		return ""
+	}
+
+	raw = strings.ToLower(raw)
+	return raw[0:8] + "-" + raw[8:12] + "-" + raw[12:16] + "-" + raw[16:20] + "-" + raw[20:32]
+}
+
+// DebugIDFromSource returns the debug ID injected into a minified source with a
+// "//# debugId=" comment, or an empty string if there is none
+func DebugIDFromSource(source string) string {
+	matches := debugIDCommentRegex.FindAllStringSubmatch(source, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	return normalizeDebugID(matches[len(matches)-1][1])
+}
+
+// DebugIDFromSourceMap returns the "debugId" (or older "debug_id") field of a source map,
+// or an empty string if there is none
+func DebugIDFromSourceMap(sourceMap string) (string, error) {
+	var sm struct {
+		DebugID       string `json:"debugId"`
+		LegacyDebugID string `json:"debug_id"`
+	}
+
+	if err := json.Unmarshal([]byte(sourceMap), &sm); err != nil {
+		return "", err
+	}
+
+	if sm.DebugID != "" {
+		return normalizeDebugID(sm.DebugID), nil
+	}
+
+	return normalizeDebugID(sm.LegacyDebugID), nil
+}
+
+// DebugID returns the debug ID of the source map, falling back to the one in the minified source
+func (s *SourceMapCache) DebugID() string {
+	return s.debugID
+}
+
+// debugIDOf finds the debug ID of a new cache, the inputs are only decoded when they mention one
+func debugIDOf(source, sourceMap string) string {
+	if strings.Contains(sourceMap, `"debugId"`) || strings.Contains(sourceMap, `"debug_id"`) {
+		if id, err := DebugIDFromSourceMap(sourceMap); err == nil && id != "" {
+			return id
+		}
+	}
+
+	if strings.Contains(source, "debugId=") {
+		return DebugIDFromSource(source)
+	}
+
+	return ""
+}
+
+// SourceMapRegistry holds SourceMapCaches by debug ID, either only in memory or backed by a directory
+type SourceMapRegistry struct {
+	dir       string
+	maxCaches int
+
+	mu      sync.Mutex
+	entries map[string]*registryEntry
+	// lru holds the loaded entries, the most recently used first
+	lru *list.List
+}
+
+// registryEntry is a cache of the registry, callers of Get wait on done while it is loaded
+type registryEntry struct {
+	id   string
+	done chan struct{}
+	smc  *SourceMapCache
+	err  error
+	elem *list.Element
+}
+
+// SourceMapRegistryOption configures a SourceMapRegistry
+type SourceMapRegistryOption func(*SourceMapRegistry)
+
+// WithMaxSourceMapCaches keeps at most n caches in memory, evicting the least recently used.
+// A registry backed by a directory loads evicted caches again on the next Get, an in memory
+// registry forgets them. n <= 0 keeps every cache, which is the default.
+func WithMaxSourceMapCaches(n int) SourceMapRegistryOption {
+	return func(r *SourceMapRegistry) {
+		r.maxCaches = n
+	}
+}
+
+// NewSourceMapRegistry creates a registry that only keeps its caches in memory
+func NewSourceMapRegistry(opts ...SourceMapRegistryOption) *SourceMapRegistry {
+	return newSourceMapRegistry("", opts)
+}
+
+// NewSourceMapRegistryFromDir creates a registry that stores minified sources and source maps
+// in dir as <debug id>.js and <debug id>.js.map and loads them on first use
+func NewSourceMapRegistryFromDir(dir string, opts ...SourceMapRegistryOption) (*SourceMapRegistry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return newSourceMapRegistry(dir, opts), nil
+}
+
+func newSourceMapRegistry(dir string, opts []SourceMapRegistryOption) *SourceMapRegistry {
+	r := &SourceMapRegistry{
+		dir:     dir,
+		entries: make(map[string]*registryEntry),
+		lru:     list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Add registers a minified source and its source map under their debug ID, which is returned.
+// The source and the map have to agree on the debug ID when both carry one.
+func (r *SourceMapRegistry) Add(source, sourceMap string) (string, error) {
+	mapID, err := DebugIDFromSourceMap(sourceMap)
+	if err != nil {
+		return "", err
+	}
+
+	sourceID := DebugIDFromSource(source)
+
+	if mapID != "" && sourceID != "" && mapID != sourceID {
+		return "", fmt.Errorf("debug ID of the source %s does not match the source map %s", sourceID, mapID)
+	}
+
+	id := mapID
+	if id == "" {
+		id = sourceID
+	}
+	if id == "" {
+		return "", errors.New("neither the source nor the source map has a debug ID")
+	}
+
+	smc, err := NewSourceMapCache(source, sourceMap)
+	if err != nil {
+		return "", err
+	}
+
+	if r.dir != "" {
+		if err := os.WriteFile(filepath.Join(r.dir, id+".js"), []byte(source), 0o644); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(r.dir, id+".js.map"), []byte(sourceMap), 0o644); err != nil {
+			return "", err
+		}
+	}
+
+	entry := &registryEntry{id: id, done: make(chan struct{}), smc: smc}
+	close(entry.done)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.entries[id]; ok && old.elem != nil {
+		r.lru.Remove(old.elem)
+	}
+	r.entries[id] = entry
+	r.cache(entry)
+
+	return id, nil
+}
+
+// Get returns the SourceMapCache for the debug ID, or nil if the registry does not know it.
+// Caches are loaded from the directory outside of the registry's lock, concurrent callers
+// for the same debug ID wait for a single load.
+func (r *SourceMapRegistry) Get(debugID string) (*SourceMapCache, error) {
+	id := normalizeDebugID(debugID)
+	if id == "" {
+		return nil, fmt.Errorf("invalid debug ID %q", debugID)
+	}
+
+	r.mu.Lock()
+	if entry, ok := r.entries[id]; ok {
+		if entry.elem != nil {
+			r.lru.MoveToFront(entry.elem)
+		}
+		r.mu.Unlock()
+
+		<-entry.done
+		return entry.smc, entry.err
+	}
+
+	if r.dir == "" {
+		r.mu.Unlock()
+		return nil, nil
+	}
+
+	entry := &registryEntry{id: id, done: make(chan struct{})}
+	r.entries[id] = entry
+	r.mu.Unlock()
+
+	entry.smc, entry.err = r.load(id)
+	close(entry.done)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.entries[id] == entry {
+		if entry.smc == nil {
+			// unknown debug IDs and failed loads are tried again on the next Get
+			delete(r.entries, id)
+		} else {
+			r.cache(entry)
+		}
+	}
+
+	return entry.smc, entry.err
+}
+
+// load reads the cache of the debug ID from the registry's directory
+func (r *SourceMapRegistry) load(id string) (*SourceMapCache, error) {
+	sourceMap, err := os.ReadFile(filepath.Join(r.dir, id+".js.map"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the minified source only improves function names, the map alone is enough to resolve locations
+	source, err := os.ReadFile(filepath.Join(r.dir, id+".js"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return NewSourceMapCacheFromSourceMap(string(sourceMap))
+	case err != nil:
+		return nil, err
+	}
+
+	return NewSourceMapCache(string(source), string(sourceMap))
+}
+
+// cache adds a loaded entry to the front of the lru and evicts the least recently used
+// ones over the limit, r.mu has to be held
+func (r *SourceMapRegistry) cache(entry *registryEntry) {
+	entry.elem = r.lru.PushFront(entry)
+
+	for r.maxCaches > 0 && r.lru.Len() > r.maxCaches {
+		oldest := r.lru.Remove(r.lru.Back()).(*registryEntry)
+		oldest.elem = nil
+		delete(r.entries, oldest.id)
+	}
+}
+
+// Resolver returns a SourceMapCacheResolver for SymbolicateJSStack that looks up
+// scripts by the debug IDs reported for their urls
+func (r *SourceMapRegistry) Resolver(debugIDs map[string]string) SourceMapCacheResolver {
+	return func(url string) (*SourceMapCache, error) {
+		id, ok := debugIDs[url]
+		if !ok {
+			return nil, nil
+		}
+
+		return r.Get(id)
+	}
+}
@@ -0,0 +1,160 @@
+package symbolic
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugIDFromSource(t *testing.T) {
+	assert.Equal(t, "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10", DebugIDFromSource("var a=1;\n//# debugId=B8A5C5A7-5C5E-4C51-8F2B-4C3B1A7E9D10\n//# sourceMappingURL=app.js.map"))
+	assert.Equal(t, "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10", DebugIDFromSource("//# debugId=b8a5c5a75c5e4c518f2b4c3b1a7e9d10"))
+	assert.Equal(t, "", DebugIDFromSource("//# debugId=not-a-uuid"))
+	assert.Equal(t, "", DebugIDFromSource("var a=1;"))
+}
+
+func TestDebugIDFromSourceMap(t *testing.T) {
+	id, err := DebugIDFromSourceMap(`{"version":3,"debugId":"B8A5C5A7-5C5E-4C51-8F2B-4C3B1A7E9D10"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10", id)
+
+	id, err = DebugIDFromSourceMap(`{"version":3,"debug_id":"b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10", id)
+
+	id, err = DebugIDFromSourceMap(`{"version":3}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "", id)
+
+	_, err = DebugIDFromSourceMap(`not json`)
+	assert.Error(t, err)
+}
+
+func TestSourceMapRegistry(t *testing.T) {
+	minfied, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/simple/minified.js")
+	assert.NoError(t, err)
+	sourceMap, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/sourcemapcache/simple/minified.js.map")
+	assert.NoError(t, err)
+
+	id := "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10"
+	source := string(minfied) + "\n//# debugId=" + id
+
+	dir := t.TempDir()
+	registry, err := NewSourceMapRegistryFromDir(dir)
+	assert.NoError(t, err)
+
+	added, err := registry.Add(source, string(sourceMap))
+	assert.NoError(t, err)
+	assert.Equal(t, id, added)
+
+	_, err = registry.Add(string(minfied), string(sourceMap))
+	assert.Error(t, err, "sources without a debug ID can not be registered")
+
+	// a new registry on the same directory loads the cache from disk
+	registry, err = NewSourceMapRegistryFromDir(dir)
+	assert.NoError(t, err)
+
+	smc, err := registry.Get("B8A5C5A75C5E4C518F2B4C3B1A7E9D10")
+	assert.NoError(t, err)
+	if !assert.NotNil(t, smc) {
+		return
+	}
+	assert.Equal(t, id, smc.DebugID())
+
+	token, err := smc.Lookup(OneBased(1, 11), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "tests/fixtures/simple/original.js", token.Src)
+
+	smc, err = registry.Get("00000000-0000-0000-0000-000000000000")
+	assert.NoError(t, err)
+	assert.Nil(t, smc)
+
+	_, err = registry.Get("nope")
+	assert.Error(t, err)
+
+	frames := SymbolicateJSStack("    at foo (http://example.com/minified.js:1:11)", registry.Resolver(map[string]string{
+		"http://example.com/minified.js": id,
+	}), 0)
+	assert.Len(t, frames, 1)
+	assert.NoError(t, frames[0].Err)
+	assert.Equal(t, "tests/fixtures/simple/original.js", frames[0].Token.Src)
+}
+
+func TestSourceMapRegistryLimit(t *testing.T) {
+	ids := []string{
+		"b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10",
+		"c9b6d6b8-6d6f-4d62-9a3c-5d4c2b8fae21",
+	}
+	sourceMap := `{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA"}`
+
+	// an in memory registry forgets the evicted caches
+	registry := NewSourceMapRegistry(WithMaxSourceMapCaches(1))
+	for _, id := range ids {
+		_, err := registry.Add("a();\n//# debugId="+id, sourceMap)
+		assert.NoError(t, err)
+	}
+
+	smc, err := registry.Get(ids[0])
+	assert.NoError(t, err)
+	assert.Nil(t, smc)
+	smc, err = registry.Get(ids[1])
+	assert.NoError(t, err)
+	assert.NotNil(t, smc)
+
+	// a registry backed by a directory loads them again
+	dir := t.TempDir()
+	registry, err = NewSourceMapRegistryFromDir(dir, WithMaxSourceMapCaches(1))
+	assert.NoError(t, err)
+	for _, id := range ids {
+		_, err := registry.Add("a();\n//# debugId="+id, sourceMap)
+		assert.NoError(t, err)
+	}
+
+	first, err := registry.Get(ids[0])
+	assert.NoError(t, err)
+	assert.NotNil(t, first)
+	again, err := registry.Get(ids[0])
+	assert.NoError(t, err)
+	assert.Same(t, first, again)
+
+	_, err = registry.Get(ids[1])
+	assert.NoError(t, err)
+	again, err = registry.Get(ids[0])
+	assert.NoError(t, err)
+	assert.NotNil(t, again)
+	assert.NotSame(t, first, again)
+}
+
+func TestSourceMapRegistryConcurrentGet(t *testing.T) {
+	id := "b8a5c5a7-5c5e-4c51-8f2b-4c3b1a7e9d10"
+
+	dir := t.TempDir()
+	registry, err := NewSourceMapRegistryFromDir(dir)
+	assert.NoError(t, err)
+	_, err = registry.Add("a();\n//# debugId="+id, `{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA"}`)
+	assert.NoError(t, err)
+
+	// a fresh registry on the same directory loads the cache once for all callers
+	registry, err = NewSourceMapRegistryFromDir(dir)
+	assert.NoError(t, err)
+
+	caches := make([]*SourceMapCache, 8)
+	var wg sync.WaitGroup
+	for i := range caches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			smc, err := registry.Get(id)
+			assert.NoError(t, err)
+			caches[i] = smc
+		}(i)
+	}
+	wg.Wait()
+
+	assert.NotNil(t, caches[0])
+	for _, smc := range caches {
+		assert.Same(t, caches[0], smc)
+	}
+}
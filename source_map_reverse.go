@@ -0,0 +1,148 @@
+package symbolic
+
+import (
+	"slices"
+	"sort"
+)
+
+// Bias selects which mapping is used when no mapping starts exactly at the requested original position
+type Bias int
+
+const (
+	// GreatestLowerBound picks the closest mapping before the position
+	GreatestLowerBound Bias = iota
+	// LeastUpperBound picks the closest mapping after the position
+	LeastUpperBound
+)
+
+// GeneratedRange is a range of the generated source that maps to an original position
+type GeneratedRange struct {
+	// Original is the original position the range maps to
+	Original Position
+	// Start is the first generated position of the range
+	Start Position
+	// End is the generated position where the next mapping starts (exclusive). The last
+	// mapping of the generated source extends to its end and has a zero End.
+	End Position
+}
+
+// GeneratedPositionsFor returns all generated ranges that map to the given position in the
+// original source src, which can be given with or without the sourceRoot or normalized. When no mapping
+// starts exactly at pos, bias selects the closest mapping on the same line.
+// The cache has to be created WithRetainedInputs, ErrInputsNotRetained is returned otherwise.
+func (s *SourceMapCache) GeneratedPositionsFor(src string, pos Position, bias Bias) ([]GeneratedRange, error) {
+	index, err := s.reverse()
+	if err != nil {
+		return nil, err
+	}
+
+	ranges, ok := index[src]
+	if !ok {
+		return nil, nil
+	}
+
+	line, _ := pos.ZeroBased()
+
+	// ranges are sorted by original position, find the first one at or after pos
+	i := sort.Search(len(ranges), func(i int) bool {
+		return !positionLess(ranges[i].Original, pos)
+	})
+
+	var target Position
+	switch {
+	case i < len(ranges) && ranges[i].Original == pos:
+		target = pos
+	case bias == GreatestLowerBound && i > 0:
+		target = ranges[i-1].Original
+	case bias == LeastUpperBound && i < len(ranges):
+		target = ranges[i].Original
+	default:
+		return nil, nil
+	}
+
+	if targetLine, _ := target.ZeroBased(); targetLine != line {
+		return nil, nil
+	}
+
+	// all ranges for the target are next to each other
+	start := sort.Search(len(ranges), func(i int) bool {
+		return !positionLess(ranges[i].Original, target)
+	})
+	end := start
+	for end < len(ranges) && ranges[end].Original == target {
+		end++
+	}
+
+	result := make([]GeneratedRange, end-start)
+	copy(result, ranges[start:end])
+
+	return result, nil
+}
+
+// reverse builds the index from original sources to generated ranges once
+func (s *SourceMapCache) reverse() (map[string][]GeneratedRange, error) {
+	s.reverseOnce.Do(func() {
+		sm, err := s.raw()
+		if err != nil {
+			s.reverseErr = err
+			return
+		}
+
+		// the sections of an index map each have their own sourceRoot
+		var mappings []Mapping
+		var roots []string
+		it := newMappingIterator(sm)
+		for it.Next() {
+			mappings = append(mappings, it.Mapping())
+			roots = append(roots, it.sourceRoot())
+		}
+		if err := it.Err(); err != nil {
+			s.reverseErr = err
+			return
+		}
+
+		index := make(map[string][]GeneratedRange)
+		for i, m := range mappings {
+			if !m.HasSource {
+				continue
+			}
+
+			r := GeneratedRange{
+				Original: m.Original,
+				Start:    m.Generated,
+			}
+			if i+1 < len(mappings) {
+				r.End = mappings[i+1].Generated
+			}
+
+			rooted := joinSourceRoot(roots[i], m.Source)
+			keys := []string{m.Source, rooted, s.normalizeSrc(m.Source), s.normalizeSrc(rooted)}
+			for k, key := range keys {
+				if !slices.Contains(keys[:k], key) {
+					index[key] = append(index[key], r)
+				}
+			}
+		}
+
+		for _, ranges := range index {
+			sort.SliceStable(ranges, func(i, j int) bool {
+				return positionLess(ranges[i].Original, ranges[j].Original)
+			})
+		}
+
+		s.reverseIndex = index
+	})
+
+	return s.reverseIndex, s.reverseErr
+}
+
+func positionLess(a, b Position) bool {
+	aLine, aCol := a.ZeroBased()
+	bLine, bCol := b.ZeroBased()
+
+	if aLine != bLine {
+		return aLine < bLine
+	}
+
+	return aCol < bCol
+}
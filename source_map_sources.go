@@ -0,0 +1,52 @@
+package symbolic
+
+import "errors"
+
+// ErrNoSourceContents is returned when a source map does not embed the contents of a source
+var ErrNoSourceContents = errors.New("source map has no sourcesContent for this source")
+
+// Sources returns the names of the original sources referenced by the source map.
+// The cache has to be created WithRetainedInputs, ErrInputsNotRetained is returned otherwise.
+func (s *SourceMapCache) Sources() ([]string, error) {
+	sm, err := s.raw()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var sources []string
+
+	for _, f := range sm.sourceFiles() {
+		if seen[f.name] {
+			continue
+		}
+		seen[f.name] = true
+		sources = append(sources, f.name)
+	}
+
+	return sources, nil
+}
+
+// SourceContents returns the full original file embedded in the source map's sourcesContent.
+// name can either be an entry of Sources or the Src of a SourceMapCacheToken, which may include the sourceRoot
+// or be normalized.
+// ErrNoSourceContents is returned when the source map does not embed the file, and
+// ErrInputsNotRetained when the cache was not created WithRetainedInputs.
+func (s *SourceMapCache) SourceContents(name string) (string, error) {
+	sm, err := s.raw()
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range sm.sourceFiles() {
+		if f.content == nil {
+			continue
+		}
+
+		if f.name == name || sm.withSourceRoot(f.name) == name || s.normalizeSrc(f.name) == name {
+			return *f.content, nil
+		}
+	}
+
+	return "", ErrNoSourceContents
+}
@@ -0,0 +1,71 @@
+package symbolic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVLQ(t *testing.T) {
+	values, err := decodeVLQ("AAgBC")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 0, 16, 1}, values)
+
+	values, err = decodeVLQ("D")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-1}, values)
+
+	for _, v := range []int64{0, 1, -1, 15, 16, -16, 1000, -123456, 1 << 40} {
+		var sb strings.Builder
+		encodeVLQ(&sb, v)
+		decoded, err := decodeVLQ(sb.String())
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{v}, decoded)
+	}
+
+	_, err = decodeVLQ("g")
+	assert.Error(t, err)
+
+	_, err = decodeVLQ("A!")
+	assert.Error(t, err)
+}
+
+func TestDecodeMappings(t *testing.T) {
+	sm, err := parseRawSourceMap(`{"version":3,"sources":["a.js","b.js"],"names":["foo"],"mappings":"AAAA,EAAEA;ACCA,C"}`)
+	assert.NoError(t, err)
+
+	mappings, err := sm.decodeMappings()
+	assert.NoError(t, err)
+	assert.Equal(t, []Mapping{
+		{Generated: ZeroBased(0, 0), HasSource: true, Source: "a.js", Original: ZeroBased(0, 0)},
+		{Generated: ZeroBased(0, 2), HasSource: true, Source: "a.js", Original: ZeroBased(0, 2), Name: "foo"},
+		{Generated: ZeroBased(1, 0), HasSource: true, Source: "b.js", Original: ZeroBased(1, 2)},
+		{Generated: ZeroBased(1, 1)},
+	}, mappings)
+
+	encoded, err := encodeSourceMap("", mappings)
+	assert.NoError(t, err)
+
+	roundtrip, err := parseRawSourceMap(encoded)
+	assert.NoError(t, err)
+	decoded, err := roundtrip.decodeMappings()
+	assert.NoError(t, err)
+	assert.Equal(t, mappings, decoded)
+
+	sm, err = parseRawSourceMap(`{"version":3,"sections":[{"offset":{"line":0,"column":0},"map":{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA"}},{"offset":{"line":2,"column":5},"map":{"version":3,"sources":["b.js"],"names":[],"mappings":"CAAC;AACA"}}]}`)
+	assert.NoError(t, err)
+
+	mappings, err = sm.decodeMappings()
+	assert.NoError(t, err)
+	assert.Equal(t, []Mapping{
+		{Generated: ZeroBased(0, 0), HasSource: true, Source: "a.js", Original: ZeroBased(0, 0)},
+		{Generated: ZeroBased(2, 6), HasSource: true, Source: "b.js", Original: ZeroBased(0, 1)},
+		{Generated: ZeroBased(3, 0), HasSource: true, Source: "b.js", Original: ZeroBased(1, 1)},
+	}, mappings)
+
+	sm, err = parseRawSourceMap(`{"version":3,"sources":["a.js"],"names":[],"mappings":"ACAA"}`)
+	assert.NoError(t, err)
+	_, err = sm.decodeMappings()
+	assert.Error(t, err)
+}
@@ -0,0 +1,274 @@
+package symbolic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SourceMapIssueKind classifies the problems ValidateSourceMap reports
+type SourceMapIssueKind int
+
+const (
+	// InvalidStructure is reported for missing or conflicting top level fields
+	InvalidStructure SourceMapIssueKind = iota
+	// InvalidVLQ is reported for segments that are not valid base64 VLQ
+	InvalidVLQ
+	// InvalidSegment is reported for segments with the wrong number of fields or negative positions
+	InvalidSegment
+	// SourceOutOfRange is reported for segments referencing a source that does not exist
+	SourceOutOfRange
+	// NameOutOfRange is reported for segments referencing a name that does not exist
+	NameOutOfRange
+)
+
+func (k SourceMapIssueKind) String() string {
+	switch k {
+	case InvalidStructure:
+		return "invalid structure"
+	case InvalidVLQ:
+		return "invalid VLQ"
+	case InvalidSegment:
+		return "invalid segment"
+	case SourceOutOfRange:
+		return "source out of range"
+	case NameOutOfRange:
+		return "name out of range"
+	}
+	return fmt.Sprintf("SourceMapIssueKind(%d)", int(k))
+}
+
+// SourceMapIssue is a single problem found in a source map
+type SourceMapIssue struct {
+	Kind SourceMapIssueKind
+	// Offset is the byte offset of the segment in the mappings field, -1 for issues outside of the mappings
+	Offset int
+	// Line is the 0-based generated line of the segment
+	Line    uint32
+	Segment string
+	Message string
+}
+
+// SourceMapSectionReport is the report of one section of an index map
+type SourceMapSectionReport struct {
+	// Line and Column are the 0-based offset of the section in the generated source
+	Line   uint32
+	Column uint32
+	Report *SourceMapReport
+}
+
+// SourceMapReport describes a source map and the problems found in it
+type SourceMapReport struct {
+	Version     int
+	File        string
+	SourceRoot  string
+	SourceCount int
+	NameCount   int
+	// MappingCount is the number of valid segments
+	MappingCount int
+	// Sections is only set for index maps
+	Sections []SourceMapSectionReport
+	Issues   []SourceMapIssue
+}
+
+// ValidateSourceMap checks a source map without building a SourceMapCache, which accepts
+// maps that would only ever produce empty tokens. An error is only returned when the
+// source map is not JSON, every other problem, including fields of the wrong type, is part of the report.
+func ValidateSourceMap(sourceMap string) (*SourceMapReport, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(sourceMap), &fields); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, err
+		}
+
+		report := &SourceMapReport{}
+		report.structural("source map must be a JSON object")
+		return report, nil
+	}
+
+	return validateFields(fields), nil
+}
+
+// structural adds an InvalidStructure issue to the report
+func (r *SourceMapReport) structural(format string, args ...any) {
+	r.Issues = append(r.Issues, SourceMapIssue{
+		Kind:    InvalidStructure,
+		Offset:  -1,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// decodeField decodes a field of the source map, a value of the wrong type is reported and
+// treated as missing. ok is false when the field is missing or has the wrong type.
+func decodeField[T any](report *SourceMapReport, fields map[string]json.RawMessage, key, want string) (v T, ok bool) {
+	raw, present := fields[key]
+	if !present {
+		return v, false
+	}
+
+	if err := json.Unmarshal(raw, &v); err != nil {
+		report.structural("%s must be %s", key, want)
+		var zero T
+		return zero, false
+	}
+
+	return v, true
+}
+
+func validateFields(fields map[string]json.RawMessage) *SourceMapReport {
+	report := &SourceMapReport{}
+
+	sm := &rawSourceMap{}
+	version, versionOK := decodeField[int](report, fields, "version", "a number")
+	sm.Version = version
+	sm.File, _ = decodeField[string](report, fields, "file", "a string")
+	sm.SourceRoot, _ = decodeField[string](report, fields, "sourceRoot", "a string")
+	sm.Sources, _ = decodeField[[]*string](report, fields, "sources", "an array of strings")
+	sm.SourcesContent, _ = decodeField[[]*string](report, fields, "sourcesContent", "an array of strings")
+	sm.Names, _ = decodeField[[]string](report, fields, "names", "an array of strings")
+	sm.Mappings, _ = decodeField[string](report, fields, "mappings", "a string")
+	sm.IgnoreList, _ = decodeField[[]int](report, fields, "ignoreList", "an array of numbers")
+
+	report.Version = sm.Version
+	report.File = sm.File
+	report.SourceRoot = sm.SourceRoot
+	report.SourceCount = len(sm.Sources)
+	report.NameCount = len(sm.Names)
+
+	// a version of the wrong type is already reported
+	if _, present := fields["version"]; (versionOK || !present) && sm.Version != 3 {
+		report.structural("unsupported version %d, expected 3", sm.Version)
+	}
+
+	_, hasMappings := fields["mappings"]
+	_, hasSections := fields["sections"]
+
+	switch {
+	case hasSections && hasMappings:
+		report.structural("index map must not have mappings")
+	case !hasSections && !hasMappings:
+		report.structural("missing mappings")
+	case !hasSections:
+		if _, ok := fields["sources"]; !ok {
+			report.structural("missing sources")
+		}
+		if len(sm.SourcesContent) > len(sm.Sources) {
+			report.structural("sourcesContent has %d entries for %d sources", len(sm.SourcesContent), len(sm.Sources))
+		}
+		for _, i := range sm.IgnoreList {
+			if i < 0 || i >= len(sm.Sources) {
+				report.structural("ignoreList references source %d out of range", i)
+			}
+		}
+	}
+
+	if hasSections {
+		validateSections(fields, report)
+		return report
+	}
+
+	it := newMappingIterator(sm)
+	it.onError = func(offset int, line uint32, segment string, err *segmentError) {
+		report.Issues = append(report.Issues, SourceMapIssue{
+			Kind:    err.kind,
+			Offset:  offset,
+			Line:    line,
+			Segment: segment,
+			Message: err.msg,
+		})
+	}
+	for it.Next() {
+		report.MappingCount++
+	}
+
+	return report
+}
+
+func validateSections(fields map[string]json.RawMessage, report *SourceMapReport) {
+	sections, ok := decodeField[[]map[string]json.RawMessage](report, fields, "sections", "an array of objects")
+	if !ok {
+		return
+	}
+
+	var prevLine, prevColumn uint32
+	for i, section := range sections {
+		var off struct {
+			Line   uint32 `json:"line"`
+			Column uint32 `json:"column"`
+		}
+		if raw, ok := section["offset"]; ok && json.Unmarshal(raw, &off) != nil {
+			report.structural("section %d offset must be an object with a line and column", i)
+			off.Line, off.Column = 0, 0
+		}
+
+		if i > 0 && (off.Line < prevLine || (off.Line == prevLine && off.Column < prevColumn)) {
+			report.structural("section %d starts before the previous section", i)
+		}
+		prevLine, prevColumn = off.Line, off.Column
+
+		sectionReport := SourceMapSectionReport{
+			Line:   off.Line,
+			Column: off.Column,
+		}
+
+		var mapFields map[string]json.RawMessage
+		if raw, ok := section["map"]; !ok || json.Unmarshal(raw, &mapFields) != nil || mapFields == nil {
+			report.structural("section %d has no map", i)
+		} else {
+			sectionReport.Report = validateFields(mapFields)
+			report.MappingCount += sectionReport.Report.MappingCount
+		}
+
+		report.Sections = append(report.Sections, sectionReport)
+	}
+}
+
+// Valid reports whether neither the source map nor any of its sections has issues
+func (r *SourceMapReport) Valid() bool {
+	if len(r.Issues) > 0 {
+		return false
+	}
+
+	for _, s := range r.Sections {
+		if s.Report != nil && !s.Report.Valid() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Err returns nil for a valid source map, otherwise an error describing the first issues
+func (r *SourceMapReport) Err() error {
+	var msgs []string
+	r.collect("", &msgs)
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	const max = 5
+	if len(msgs) > max {
+		msgs = append(msgs[:max], fmt.Sprintf("and %d more issues", len(msgs)-max))
+	}
+
+	return errors.New("invalid source map: " + strings.Join(msgs, "; "))
+}
+
+func (r *SourceMapReport) collect(prefix string, msgs *[]string) {
+	for _, issue := range r.Issues {
+		if issue.Offset >= 0 {
+			*msgs = append(*msgs, fmt.Sprintf("%s%s at mappings offset %d (line %d): %s", prefix, issue.Kind, issue.Offset, issue.Line, issue.Message))
+		} else {
+			*msgs = append(*msgs, fmt.Sprintf("%s%s: %s", prefix, issue.Kind, issue.Message))
+		}
+	}
+
+	for i, s := range r.Sections {
+		if s.Report != nil {
+			s.Report.collect(fmt.Sprintf("%ssection %d: ", prefix, i), msgs)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package symbolic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSourceMap(t *testing.T) {
+	report, err := ValidateSourceMap(`{"version":3,"file":"app.js","sourceRoot":"/src","sources":["a.js"],"names":["foo"],"mappings":"AAAAA,EAAE"}`)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid())
+	assert.NoError(t, report.Err())
+	assert.Equal(t, 3, report.Version)
+	assert.Equal(t, "app.js", report.File)
+	assert.Equal(t, "/src", report.SourceRoot)
+	assert.Equal(t, 1, report.SourceCount)
+	assert.Equal(t, 1, report.NameCount)
+	assert.Equal(t, 2, report.MappingCount)
+
+	// what TestMin accepts
+	report, err = ValidateSourceMap("{}")
+	assert.NoError(t, err)
+	assert.False(t, report.Valid())
+	assert.Error(t, report.Err())
+
+	report, err = ValidateSourceMap(`{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA,!A;ACAA,ADAAC,AAA"}`)
+	assert.NoError(t, err)
+	assert.False(t, report.Valid())
+	assert.Equal(t, 1, report.MappingCount)
+	assert.Equal(t, []SourceMapIssue{
+		{Kind: InvalidVLQ, Offset: 5, Line: 0, Segment: "!A", Message: `invalid base64 character '!' in segment "!A"`},
+		{Kind: SourceOutOfRange, Offset: 8, Line: 1, Segment: "ACAA", Message: `segment "ACAA" references source 1 out of range`},
+		{Kind: NameOutOfRange, Offset: 13, Line: 1, Segment: "ADAAC", Message: `segment "ADAAC" references name 1 out of range`},
+		{Kind: InvalidSegment, Offset: 19, Line: 1, Segment: "AAA", Message: `segment "AAA" has 3 fields`},
+	}, report.Issues)
+	assert.Contains(t, report.Err().Error(), "invalid VLQ at mappings offset 5 (line 0)")
+
+	report, err = ValidateSourceMap(`{"version":3,"sections":[{"offset":{"line":0,"column":0},"map":{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA"}},{"offset":{"line":1,"column":0},"map":{"version":3,"sources":[],"names":[],"mappings":"AAAA"}}]}`)
+	assert.NoError(t, err)
+	assert.False(t, report.Valid())
+	assert.Len(t, report.Sections, 2)
+	assert.True(t, report.Sections[0].Report.Valid())
+	assert.Equal(t, uint32(1), report.Sections[1].Line)
+	assert.Equal(t, SourceOutOfRange, report.Sections[1].Report.Issues[0].Kind)
+	assert.Contains(t, report.Err().Error(), "section 1: source out of range")
+
+	_, err = ValidateSourceMap("not json")
+	assert.Error(t, err)
+}
+
+func TestValidateSourceMapWrongTypes(t *testing.T) {
+	for _, tc := range []struct {
+		sourceMap string
+		message   string
+	}{
+		{`{"version":"3","sources":["a.js"],"names":[],"mappings":"AAAA"}`, "version must be a number"},
+		{`{"version":3,"sources":"a.js","names":[],"mappings":"AAAA"}`, "sources must be an array of strings"},
+		{`{"version":3,"sources":["a.js"],"names":[],"mappings":1}`, "mappings must be a string"},
+		{`{"version":3,"sources":["a.js"],"names":[1],"mappings":"AAAA"}`, "names must be an array of strings"},
+		{`{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA","ignoreList":"0"}`, "ignoreList must be an array of numbers"},
+		{`{"version":3,"sections":{}}`, "sections must be an array of objects"},
+		{`{"version":3,"sections":[{"offset":[0,0],"map":{"version":3,"sources":[],"names":[],"mappings":""}}]}`, "section 0 offset must be an object with a line and column"},
+		{`[]`, "source map must be a JSON object"},
+	} {
+		report, err := ValidateSourceMap(tc.sourceMap)
+		if !assert.NoError(t, err, tc.sourceMap) {
+			continue
+		}
+
+		assert.False(t, report.Valid(), tc.sourceMap)
+		assert.Contains(t, report.Issues, SourceMapIssue{Kind: InvalidStructure, Offset: -1, Message: tc.message}, tc.sourceMap)
+	}
+
+	// the fields of the right type are still checked
+	report, err := ValidateSourceMap(`{"version":"3","sources":["a.js"],"names":[],"mappings":"AAAA,CCAA"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.SourceCount)
+	assert.Equal(t, 1, report.MappingCount)
+	assert.Len(t, report.Issues, 2)
+	assert.Equal(t, SourceOutOfRange, report.Issues[1].Kind)
+}
@@ -0,0 +1,157 @@
+package symbolic
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// SourcePathOptions configures NormalizeSourcePath
+type SourcePathOptions struct {
+	// SourceRoot is joined with relative source paths that do not already start with it
+	SourceRoot string
+	// StripBundlerSchemes removes bundler prefixes like webpack://my-app/, webpack-internal:///, vite: and rollup://
+	StripBundlerSchemes bool
+	// Rewrites are applied last, the first rewrite whose From prefix matches replaces it with To
+	Rewrites []PrefixRewrite
+}
+
+// PrefixRewrite replaces the From prefix of a source path with To
+type PrefixRewrite struct {
+	From string
+	To   string
+}
+
+var bundlerSchemes = []string{"webpack-internal://", "webpack://", "rollup://", "vite:"}
+
+// NormalizeSourcePath cleans up the source path of a SourceMapCacheToken: it joins the
+// sourceRoot, strips bundler schemes, resolves "." and ".." segments and applies the rewrites.
+func NormalizeSourcePath(src string, opts SourcePathOptions) string {
+	if src == "" {
+		return src
+	}
+
+	if opts.SourceRoot != "" && !hasScheme(src) && !strings.HasPrefix(src, "/") && !hasPathPrefix(src, opts.SourceRoot) {
+		src = strings.TrimSuffix(opts.SourceRoot, "/") + "/" + src
+	}
+
+	if opts.StripBundlerSchemes {
+		src = stripBundlerScheme(src)
+	}
+
+	src = cleanSourcePath(src)
+
+	for _, r := range opts.Rewrites {
+		if strings.HasPrefix(src, r.From) {
+			src = r.To + strings.TrimPrefix(src, r.From)
+			break
+		}
+	}
+
+	return src
+}
+
+// hasPathPrefix reports whether src starts with the path prefix, which has to end at a "/" or at
+// the end of src, so "src/app.js" has the prefix "src" but "srcfoo/app.js" does not
+func hasPathPrefix(src, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(src, prefix) {
+		return false
+	}
+
+	rest := src[len(prefix):]
+	return rest == "" || rest[0] == '/'
+}
+
+func hasScheme(src string) bool {
+	i := strings.Index(src, ":")
+	return i > 0 && !strings.ContainsAny(src[:i], "/.")
+}
+
+func stripBundlerScheme(src string) string {
+	for _, scheme := range bundlerSchemes {
+		if !strings.HasPrefix(src, scheme) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(src, scheme)
+		if strings.HasSuffix(scheme, "://") {
+			// the first segment is the bundler's namespace, e.g. the "my-app" of webpack://my-app/./src/app.js
+			if i := strings.Index(rest, "/"); i >= 0 {
+				rest = rest[i+1:]
+			}
+		}
+
+		return rest
+	}
+
+	return src
+}
+
+// cleanSourcePath resolves "." and ".." segments, leading ".." of relative paths are kept
+func cleanSourcePath(src string) string {
+	if hasScheme(src) {
+		// only clean the path of urls like https://example.com/a/../b.js
+		i := strings.Index(src, "://")
+		if i < 0 {
+			return src
+		}
+		rest := src[i+3:]
+		slash := strings.Index(rest, "/")
+		if slash < 0 {
+			return src
+		}
+		return src[:i+3] + rest[:slash] + path.Clean(rest[slash:])
+	}
+
+	cleaned := path.Clean(src)
+	if cleaned == "." {
+		return src
+	}
+
+	return cleaned
+}
+
+// SourceMapCacheOption configures a SourceMapCache
+type SourceMapCacheOption func(*SourceMapCache)
+
+// WithSourcePathNormalization normalizes the Src of every token with NormalizeSourcePath.
+// When opts.SourceRoot is empty the sourceRoot of the source map is used.
+func WithSourcePathNormalization(opts SourcePathOptions) SourceMapCacheOption {
+	return func(s *SourceMapCache) {
+		s.normalize = &opts
+	}
+}
+
+// normalizeSrc applies the source path normalization the cache was created with
+func (s *SourceMapCache) normalizeSrc(src string) string {
+	if s.normalize == nil {
+		return src
+	}
+
+	opts := *s.normalize
+	if opts.SourceRoot == "" {
+		opts.SourceRoot = s.sourceRoot
+	}
+
+	return NormalizeSourcePath(src, opts)
+}
+
+// tokenSrc returns the Src a lookup returns for a source name of the source map: joined
+// with the sourceRoot by the C ABI and then normalized
+func (s *SourceMapCache) tokenSrc(sourceRoot, name string) string {
+	return s.normalizeSrc(joinSourceRoot(sourceRoot, name))
+}
+
+// sourceRootOf returns the sourceRoot of a source map without decoding the rest of it
+func sourceRootOf(sourceMap string) string {
+	var sm struct {
+		SourceRoot string `json:"sourceRoot"`
+	}
+
+	if err := json.Unmarshal([]byte(sourceMap), &sm); err != nil {
+		return ""
+	}
+
+	return sm.SourceRoot
+}
@@ -0,0 +1,63 @@
+package symbolic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeSourcePath(t *testing.T) {
+	strip := SourcePathOptions{StripBundlerSchemes: true}
+
+	for _, tc := range []struct {
+		src      string
+		opts     SourcePathOptions
+		expected string
+	}{
+		{"webpack://my-app/./src/app.js", strip, "src/app.js"},
+		{"webpack:///./src/app.js", strip, "src/app.js"},
+		{"webpack-internal:///./src/app.js", strip, "src/app.js"},
+		{"rollup://localhost/src/app.js", strip, "src/app.js"},
+		{"vite:/src/app.js", strip, "/src/app.js"},
+		{"webpack://my-app/./src/app.js", SourcePathOptions{}, "webpack://my-app/src/app.js"},
+		{"../src/app.js", SourcePathOptions{}, "../src/app.js"},
+		{"src/lib/../app.js", SourcePathOptions{}, "src/app.js"},
+		{"https://example.com/a/../b.js", SourcePathOptions{}, "https://example.com/b.js"},
+		{"app.js", SourcePathOptions{SourceRoot: "/home/ci/project/"}, "/home/ci/project/app.js"},
+		{"/home/ci/project/app.js", SourcePathOptions{SourceRoot: "/home/ci/project/"}, "/home/ci/project/app.js"},
+		{"https://example.com/app.js", SourcePathOptions{SourceRoot: "/root"}, "https://example.com/app.js"},
+		{"../src/app.js", SourcePathOptions{SourceRoot: "/build/dist"}, "/build/src/app.js"},
+		{"src/app.js", SourcePathOptions{SourceRoot: "src"}, "src/app.js"},
+		{"src/app.js", SourcePathOptions{SourceRoot: "src/"}, "src/app.js"},
+		// the root is only a prefix when it ends at a path separator
+		{"srcfoo/app.js", SourcePathOptions{SourceRoot: "src"}, "src/srcfoo/app.js"},
+		{"lib-utils/a.js", SourcePathOptions{SourceRoot: "lib"}, "lib/lib-utils/a.js"},
+		{
+			"webpack://my-app/./src/app.js",
+			SourcePathOptions{StripBundlerSchemes: true, Rewrites: []PrefixRewrite{{From: "node_modules/", To: "vendor/"}, {From: "src/", To: "app/"}}},
+			"app/app.js",
+		},
+		{"", strip, ""},
+	} {
+		assert.Equal(t, tc.expected, NormalizeSourcePath(tc.src, tc.opts), tc.src)
+	}
+}
+
+func TestSourceMapCacheSourcePathNormalization(t *testing.T) {
+	sourceMap := `{"version":3,"sources":["webpack://my-app/./src/app.js"],"names":[],"mappings":"AAAA"}`
+
+	smc, err := NewSourceMapCache("a();", sourceMap, WithSourcePathNormalization(SourcePathOptions{StripBundlerSchemes: true}), WithRetainedInputs())
+	assert.NoError(t, err)
+
+	token, err := smc.Lookup(OneBased(1, 1), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "src/app.js", token.Src)
+
+	tokens, errs := smc.LookupMany([]Position{OneBased(1, 1)}, 0)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, "src/app.js", tokens[0].Src)
+
+	ranges, err := smc.GeneratedPositionsFor("src/app.js", ZeroBased(0, 0), GreatestLowerBound)
+	assert.NoError(t, err)
+	assert.Len(t, ranges, 1)
+}
@@ -33,6 +33,15 @@ func decodeStr(s *C.SymbolicStr) string {
 	return str
 }
 
+// copyStr copies the string into Go memory without freeing it
+func copyStr(s *C.SymbolicStr) string {
+	if s.data == nil {
+		return ""
+	}
+
+	return C.GoStringN(s.data, C.int(C.strnlen(s.data, C.size_t(s.len))))
+}
+
 func checkErr() error {
 	err := C.symbolic_err_get_last_code()
 
@@ -0,0 +1,104 @@
+package symbolic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotWasm is returned by ParseWasmModule for data without the WebAssembly magic
+var ErrNotWasm = errors.New("not a WebAssembly module")
+
+// WasmModule holds the custom sections of a WebAssembly module that are needed to find
+// its debug information. The module itself, or the .debug.wasm its ExternalDebugInfoURL
+// points to, is loaded with NewArchiveFromBytes like any other object; the SymCache of
+// the DWARF inside is returned by Archive.SymCache(DebugID) and is looked up with the
+// module relative byte offsets browsers report in wasm-function[..]:0x.. frames.
+type WasmModule struct {
+	// BuildID is the hex encoded build_id section
+	BuildID string
+	// DebugID is the debug ID symbolic derives from the build ID, empty without a build ID
+	DebugID string
+	// ExternalDebugInfoURL is the external_debug_info section, the url of the split .debug.wasm
+	ExternalDebugInfoURL string
+	// SourceMapURL is the sourceMappingURL section. Wasm source maps have a single line
+	// and use the byte offset as column.
+	SourceMapURL string
+	// HasDWARF is set when the module has .debug_* sections
+	HasDWARF bool
+}
+
+var wasmMagic = []byte{0x00, 'a', 's', 'm'}
+
+// ParseWasmModule reads the custom sections of a WebAssembly module
+func ParseWasmModule(data []byte) (*WasmModule, error) {
+	if len(data) < 8 || !bytes.Equal(data[:4], wasmMagic) {
+		return nil, ErrNotWasm
+	}
+
+	m := &WasmModule{}
+	pos := 8
+
+	for pos < len(data) {
+		id := data[pos]
+		pos++
+
+		size, n := binary.Uvarint(data[pos:])
+		if n <= 0 || uint64(len(data)-pos-n) < size {
+			return nil, fmt.Errorf("truncated wasm section at offset %d", pos)
+		}
+		pos += n
+		section := data[pos : pos+int(size)]
+		pos += int(size)
+
+		// only custom sections are of interest
+		if id != 0 {
+			continue
+		}
+
+		name, payload, err := readWasmName(section)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case name == "build_id":
+			m.BuildID = hex.EncodeToString(payload)
+			m.DebugID = wasmDebugID(payload)
+		case name == "external_debug_info":
+			m.ExternalDebugInfoURL, _, err = readWasmName(payload)
+		case name == "sourceMappingURL":
+			m.SourceMapURL, _, err = readWasmName(payload)
+		case strings.HasPrefix(name, ".debug_"):
+			m.HasDWARF = true
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// readWasmName reads a length prefixed UTF-8 string and returns the rest of the data
+func readWasmName(data []byte) (string, []byte, error) {
+	size, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < size {
+		return "", nil, errors.New("truncated wasm name")
+	}
+
+	return string(data[n : n+int(size)]), data[n+int(size):], nil
+}
+
+// wasmDebugID formats the first 16 bytes of the build ID as a UUID, like symbolic does
+func wasmDebugID(buildID []byte) string {
+	if len(buildID) < 16 {
+		return ""
+	}
+
+	b := buildID[:16]
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
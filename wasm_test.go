@@ -0,0 +1,98 @@
+package symbolic
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func wasmCustomSection(name string, payload []byte) []byte {
+	body := binary.AppendUvarint(nil, uint64(len(name)))
+	body = append(body, name...)
+	body = append(body, payload...)
+
+	section := []byte{0}
+	section = binary.AppendUvarint(section, uint64(len(body)))
+	return append(section, body...)
+}
+
+func wasmString(s string) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(s))), s...)
+}
+
+func TestParseWasmModule(t *testing.T) {
+	buildID := []byte{0xbd, 0xa1, 0x8f, 0xd8, 0x5d, 0x4a, 0x4e, 0xb8, 0x93, 0x02, 0x2d, 0x6b, 0xfa, 0xd8, 0x46, 0xb1}
+
+	module := append([]byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}, wasmCustomSection("build_id", buildID)...)
+	// a type section with no types
+	module = append(module, 0x01, 0x01, 0x00)
+	module = append(module, wasmCustomSection("external_debug_info", wasmString("app.debug.wasm"))...)
+	module = append(module, wasmCustomSection("sourceMappingURL", wasmString("app.wasm.map"))...)
+
+	m, err := ParseWasmModule(module)
+	assert.NoError(t, err)
+	assert.Equal(t, "bda18fd85d4a4eb893022d6bfad846b1", m.BuildID)
+	assert.Equal(t, "bda18fd8-5d4a-4eb8-9302-2d6bfad846b1", m.DebugID)
+	assert.Equal(t, "app.debug.wasm", m.ExternalDebugInfoURL)
+	assert.Equal(t, "app.wasm.map", m.SourceMapURL)
+	assert.False(t, m.HasDWARF)
+
+	m, err = ParseWasmModule(append(module, wasmCustomSection(".debug_info", nil)...))
+	assert.NoError(t, err)
+	assert.True(t, m.HasDWARF)
+
+	_, err = ParseWasmModule([]byte("not wasm"))
+	assert.ErrorIs(t, err, ErrNotWasm)
+
+	_, err = ParseWasmModule(append(module, 0x00, 0x10))
+	assert.Error(t, err)
+}
+
+func TestWasmArchive(t *testing.T) {
+	data, err := os.ReadFile("symbolic/symbolic-testutils/fixtures/wasm/simple.wasm")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	module, err := ParseWasmModule(data)
+	assert.NoError(t, err)
+
+	archive, err := NewArchiveFromBytes(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	objects, err := archive.Objects()
+	assert.NoError(t, err)
+	for _, obj := range objects {
+		assert.Equal(t, "wasm", obj.FileFormat())
+		assert.Equal(t, module.DebugID, obj.DebugID())
+	}
+
+	symCache, ok := archive.SymCaches[module.DebugID]
+	assert.True(t, ok)
+	if ok {
+		_, err := symCache.Lookup(0x8b)
+		assert.NoError(t, err)
+	}
+}
+
+func TestSymbolicateJSStackWasmSourceMap(t *testing.T) {
+	// wasm source maps have a single line, the column is the byte offset
+	smc, err := NewSourceMapCache("", `{"version":3,"sources":["src/lib.rs"],"names":[],"mappings":"gaAIA,KACE"}`)
+	assert.NoError(t, err)
+
+	resolver := func(url string) (*SourceMapCache, error) {
+		return smc, nil
+	}
+
+	frames := SymbolicateJSStack("    at fib (https://example.com/app.wasm:wasm-function[1]:0x1a5)", resolver, 0)
+	assert.Len(t, frames, 1)
+	if assert.NotNil(t, frames[0].Token) {
+		assert.Equal(t, "src/lib.rs", frames[0].Token.Src)
+		line, _ := frames[0].Token.Position.OneBased()
+		assert.Equal(t, uint32(6), line)
+	}
+}